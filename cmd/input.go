@@ -0,0 +1,275 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/progbits/sqjson/internal/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// input is a JSON input named on the command line.
+type input struct {
+	table string // Name of the table holding the input, if any.
+	path  string // Path, glob pattern or directory of the input, or "-" for stdin.
+}
+
+// parseInputs parses the input arguments following the query. Each is a path,
+// optionally prefixed with the name of its table as in NAME=PATH. Inputs after
+// the first are otherwise named after their file name, without its extension.
+// Stdin is read if there are no arguments, or for an argument of "-".
+func parseInputs(args []string) ([]input, error) {
+	if len(args) == 0 {
+		return []input{{path: "-"}}, nil
+	}
+
+	inputs := make([]input, 0, len(args))
+	tables := make(map[string]bool)
+	stdin := false
+	for i, arg := range args {
+		in := input{path: arg}
+		if j := strings.IndexByte(arg, '='); j > 0 && isIdentifier(arg[:j]) {
+			in.table, in.path = arg[:j], arg[j+1:]
+		} else if i > 0 {
+			in.table = strings.TrimSuffix(filepath.Base(arg), filepath.Ext(arg))
+			if arg == "-" || !isIdentifier(in.table) {
+				return nil, fmt.Errorf("cannot name a table after %q, use NAME=%s", arg, arg)
+			}
+		}
+
+		if in.path == "-" {
+			if stdin {
+				return nil, errors.New("stdin can only be read once")
+			}
+			stdin = true
+		}
+		if in.table != "" {
+			if tables[strings.ToLower(in.table)] {
+				return nil, fmt.Errorf("duplicate table name %q", in.table)
+			}
+			tables[strings.ToLower(in.table)] = true
+		}
+		inputs = append(inputs, in)
+	}
+	return inputs, nil
+}
+
+// isIdentifier returns true if name can be used as a table name without
+// quoting.
+func isIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		if c != '_' && !unicode.IsLetter(c) && (i == 0 || !unicode.IsDigit(c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// isLinesFile returns true if a file name has one of the extensions commonly
+// used for newline-delimited JSON.
+func isLinesFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jsonl", ".ndjson":
+		return true
+	default:
+		return false
+	}
+}
+
+// isJSONFile returns true if a file name has one of the extensions used for
+// JSON files, which are read when expanding a directory.
+func isJSONFile(name string) bool {
+	return strings.ToLower(filepath.Ext(name)) == ".json" || isLinesFile(name)
+}
+
+// expandInput returns the files of an input. Directories are expanded to the
+// JSON files they contain, including those in subdirectories if recursive is
+// set, and glob patterns to the files and directories they match.
+func expandInput(path string, recursive bool) ([]string, error) {
+	if path == "-" {
+		return []string{path}, nil
+	}
+
+	paths := []string{path}
+	if strings.ContainsAny(path, "*?[") {
+		if _, err := os.Stat(path); err != nil {
+			matches, err := filepath.Glob(path)
+			if err != nil {
+				return nil, err
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %q", path)
+			}
+			paths = matches
+		}
+	}
+
+	files := make([]string, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || !info.IsDir() {
+			// Files that cannot be read are reported when they are opened.
+			files = append(files, path)
+			continue
+		}
+
+		dirFiles, err := expandDir(path, recursive)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, dirFiles...)
+	}
+	return files, nil
+}
+
+// expandDir returns the JSON files in a directory in lexical order, including
+// those in subdirectories if recursive is set.
+func expandDir(dir string, recursive bool) ([]string, error) {
+	if !recursive {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		files := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			if !entry.IsDir() && isJSONFile(entry.Name()) {
+				files = append(files, filepath.Join(dir, entry.Name()))
+			}
+		}
+		return files, nil
+	}
+
+	files := make([]string, 0)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && isJSONFile(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// fileError is an error reading one of the files of an input.
+type fileError struct {
+	path string
+	err  error
+}
+
+func (e *fileError) Error() string { return e.err.Error() }
+func (e *fileError) Unwrap() error { return e.err }
+
+// fileReader reads the records of each of the files of an input in turn.
+//
+// Files are opened as they are reached and closed once exhausted, so inputs of
+// many files can be read without holding them all open.
+type fileReader struct {
+	files []string
+	lines bool // Read every file as newline-delimited JSON.
+
+	next   int // Index of the next file to open.
+	path   string
+	file   io.Closer
+	parser *json.Parser
+}
+
+// newFileReader creates a reader for the files of an input.
+func newFileReader(path string, recursive, lines bool) (*fileReader, error) {
+	files, err := expandInput(path, recursive)
+	if err != nil {
+		return nil, err
+	}
+	return &fileReader{files: files, lines: lines}, nil
+}
+
+// Next returns the next record of the input, or nil once every file has been
+// read.
+func (r *fileReader) Next() (*json.ASTNode, error) {
+	for {
+		if r.parser == nil {
+			if r.next == len(r.files) {
+				return nil, nil
+			}
+			if err := r.open(r.files[r.next]); err != nil {
+				return nil, err
+			}
+			r.next++
+		}
+
+		record, err := r.parser.Next()
+		if err != nil {
+			return nil, &fileError{path: r.path, err: err}
+		}
+		if record != nil {
+			return record, nil
+		}
+		r.close()
+	}
+}
+
+// File returns the path of the file the last record was read from, or "-" for
+// stdin.
+func (r *fileReader) File() string {
+	return r.path
+}
+
+// open creates a parser reading from a file, or from stdin if the path is "-".
+//
+// The input is tokenized and parsed on demand, so only the records currently
+// being processed need to be held in memory.
+func (r *fileReader) open(path string) error {
+	r.path = path
+	fin := ioIn
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		r.file = file
+		fin = file
+	}
+
+	r.parser = json.NewParser(json.NewTokenizer(fin))
+	r.parser.Lines = r.lines || isLinesFile(path)
+	return nil
+}
+
+// close closes the current file.
+func (r *fileReader) close() {
+	if r.file != nil {
+		_ = r.file.Close()
+	}
+	r.file = nil
+	r.parser = nil
+}
+
+// errorFile returns the name of the file an error occurred reading, suitable
+// for use in diagnostics. Errors from elsewhere are attributed to the first
+// input.
+func errorFile(args []string, err error) string {
+	var fileErr *fileError
+	if errors.As(err, &fileErr) {
+		if fileErr.path == "-" {
+			return "<stdin>"
+		}
+		return fileErr.path
+	}
+
+	inputs, parseErr := parseInputs(args)
+	if parseErr != nil || inputs[0].path == "-" {
+		return "<stdin>"
+	}
+	return inputs[0].path
+}
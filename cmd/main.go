@@ -1,7 +1,7 @@
 package main
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
 	"github.com/progbits/sqjson/internal/json"
 	"github.com/progbits/sqjson/internal/sql"
@@ -10,6 +10,8 @@ import (
 
 	"io"
 	"os"
+	"strconv"
+	"strings"
 )
 
 // Global configuration.
@@ -22,79 +24,154 @@ type rootCmdVars struct {
 	inputFiles []string
 	nth        string
 	compact    bool
+	lines      bool
+	recursive  bool
+	values     bool
 }
 
-func runRootCmd(vars *rootCmdVars, cmd *cobra.Command, args []string) {
-	var err error
+// printError writes a human-readable description of err to w. Syntax errors
+// in the query or the JSON input are shown alongside the offending text.
+func printError(w io.Writer, vars *rootCmdVars, err error) {
+	var jsonError *json.SyntaxError
+	var sqlError *sql.SyntaxError
+	switch {
+	case errors.As(err, &jsonError):
+		_, _ = fmt.Fprintf(w, "sqj: %s:%d:%d: %s\n",
+			errorFile(vars.inputFiles, err), jsonError.Line, jsonError.Column, jsonError.Msg)
+		if jsonError.Snippet != "" {
+			printSnippet(w, jsonError.Snippet, jsonError.SnippetOffset)
+		}
+	case errors.As(err, &sqlError):
+		_, _ = fmt.Fprintf(w, "sqj: query:%d:%d: %s\n",
+			sqlError.Line, sqlError.Column, sqlError.Msg)
+
+		// Show the line of the query containing the offending token.
+		start := strings.LastIndex(vars.query[:sqlError.Offset], "\n") + 1
+		line := vars.query[start:]
+		if end := strings.IndexByte(line, '\n'); end >= 0 {
+			line = line[:end]
+		}
+		printSnippet(w, line, sqlError.Offset-start)
+	default:
+		_, _ = fmt.Fprintf(w, "sqj: %s\n", err)
+	}
+}
 
-	// Parse the SQL query.
-	scanner := sql.NewScanner([]byte(vars.query))
-	sqlParser := sql.NewParser(scanner)
-	stmt := sqlParser.Parse()
+// printSnippet writes a snippet of text to w with a caret pointing at the
+// character offset bytes into the snippet.
+func printSnippet(w io.Writer, snippet string, offset int) {
+	if offset < 0 {
+		offset = 0
+	} else if offset > len(snippet) {
+		offset = len(snippet)
+	}
 
-	// Excess arguments after the query string are treated as files and mean we
-	// do not read from stdin. A single file named "-" is  treated as an alias
-	// for stdin.
-	fin := ioIn
-	if len(vars.inputFiles) > 0 && vars.inputFiles[0] != "-" {
-		fin, err = os.Open(vars.inputFiles[0])
-		if err != nil {
-			panic("failed to open file")
+	// Preserve tabs so the caret lines up with the snippet.
+	pad := ""
+	for _, c := range snippet[:offset] {
+		if c == '\t' {
+			pad += "\t"
+		} else {
+			pad += " "
 		}
 	}
+	_, _ = fmt.Fprintf(w, "    %s\n    %s^\n", snippet, pad)
+}
 
-	// Read the input file to a buffer.
-	buf := bytes.NewBuffer(nil)
-	_, _ = io.Copy(buf, fin)
+func runRootCmd(vars *rootCmdVars, cmd *cobra.Command, args []string) error {
+	var err error
 
-	// Tokenize the input data.
-	tokenizer := json.Tokenizer{
-		Buf: string(buf.Bytes()),
+	// Parse the SQL query.
+	scanner := sql.NewScanner([]byte(vars.query))
+	sqlParser := sql.NewParser(scanner)
+	stmt, err := sqlParser.Parse()
+	if err != nil {
+		return err
 	}
-	tokenizer.Tokenize()
 
-	// Parse the token stream.
-	jsonParser := json.Parser{
-		Tokens: tokenizer.Tokens,
+	// Excess arguments after the query string are treated as files and mean we
+	// do not read from stdin.
+	inputs, err := parseInputs(vars.inputFiles)
+	if err != nil {
+		return err
 	}
-	jsonParser.Parse()
 
 	// Query the virtual table to generate our result ASTs.
 	clientData := vtable.ClientData{
-		JsonAst: &jsonParser.Ast,
-		SqlAst:  &stmt,
-		Query:   vars.query,
+		SqlAst: &stmt,
+		Query:  vars.query,
 	}
-	result := vtable.Exec(&clientData)
-
-	for _, node := range result {
-		json.PrettyPrint(ioOut, node, vars.compact)
-		_, _ = fmt.Fprintf(ioOut, "\n")
+	for _, in := range inputs {
+		reader, err := newFileReader(in.path, vars.recursive, vars.lines)
+		if err != nil {
+			return err
+		}
+		clientData.Inputs = append(clientData.Inputs, vtable.Input{Name: in.table, Reader: reader})
+	}
+	if vars.nth != "" {
+		nth, err := strconv.Atoi(vars.nth)
+		if err != nil {
+			return fmt.Errorf("invalid --nth value %q, expected an integer", vars.nth)
+		}
+		clientData.Nth = &nth
 	}
+	return vtable.Exec(&clientData, func(row *json.ASTNode) {
+		if !vars.values {
+			json.PrettyPrint(ioOut, row, vars.compact)
+			_, _ = fmt.Fprintf(ioOut, "\n")
+			return
+		}
+
+		for _, value := range row.Members {
+			json.PrettyPrint(ioOut, value, vars.compact)
+			_, _ = fmt.Fprintf(ioOut, "\n")
+		}
+	})
 }
 
-func main() {
+// newRootCmd creates the root command, storing its flags and arguments in vars.
+func newRootCmd(vars *rootCmdVars) *cobra.Command {
 	rootCmd := &cobra.Command{
-		Use:   "sqj 'QUERY' [FILE]",
+		Use:   "sqj 'QUERY' [FILE] [[NAME=]FILE]...",
 		Short: "Query JSON with SQL",
-		Long:  `Query JSON with SQL`,
-		Args:  cobra.MinimumNArgs(1),
-		Run: func(cmd *cobra.Command, args []string) {
-			var nth string
-			var compact bool
+		Long: `Query JSON with SQL.
 
-			cmd.Flags().StringVarP(&nth, "nth", "n", "", "The nth flag")
-			cmd.Flags().BoolVarP(&compact, "compact", "c", false, "The compact flag")
+The first input is queried as the '[]' table. Further inputs are queried as
+tables named after their file name without its extension, or as NAME if given
+as NAME=FILE.
 
-			vars := &rootCmdVars{
-				query:      args[0],
-				inputFiles: args[1:],
-				nth:        nth,
-				compact:    compact,
+Inputs may also be directories or glob patterns, in which case the records of
+each JSON file they contain are read in turn as a single input. The hidden
+_file column of each table holds the path of the file of each row.`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			vars.query = args[0]
+			vars.inputFiles = args[1:]
+			if err := runRootCmd(vars, cmd, args); err != nil {
+				printError(ioErr, vars, err)
+				os.Exit(1)
 			}
-			runRootCmd(vars, cmd, args)
 		},
 	}
 
-	rootCmd.Execute()
+	rootCmd.Flags().StringVarP(&vars.nth, "nth", "n", "",
+		"Only query the nth record of the first input, counting from 0 (negative values count back from the end)")
+	rootCmd.Flags().BoolVarP(&vars.compact, "compact", "c", false,
+		"Print each result on a single line")
+	rootCmd.Flags().BoolVarP(&vars.lines, "lines", "l", false,
+		"Read newline-delimited JSON, one record per line (default for .jsonl and .ndjson files)")
+	rootCmd.Flags().BoolVarP(&vars.recursive, "recursive", "r", false,
+		"Include JSON files in subdirectories of directory inputs")
+	rootCmd.Flags().BoolVar(&vars.values, "values", false,
+		"Print each result value on its own line, rather than each row as an object")
+
+	rootCmd.AddCommand(newSchemaCmd(&schemaCmdVars{}))
+	return rootCmd
+}
+
+func main() {
+	rootCmd := newRootCmd(&rootCmdVars{})
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
 }
@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/progbits/sqjson/internal/vtable"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -27,14 +30,14 @@ func TestCmd_InputCanHaveKeywordFields(t *testing.T) {
 		{
 			"select": "hello",
 			"index": 0,
-			"from": false,
+			"from": false
 		}
 	`
 
 	testCases := []TestCase{
 		{"select", "\"hello\""},
 		{"index", "0"},
-		{"from", "0"},
+		{"from", "false"},
 	}
 
 	for i := 0; i < len(testCases); i++ {
@@ -48,6 +51,7 @@ func TestCmd_InputCanHaveKeywordFields(t *testing.T) {
 			inputFiles: nil,
 			nth:        "",
 			compact:    false,
+			values:     true,
 		}
 		runRootCmd(&vars, nil, nil)
 
@@ -83,7 +87,20 @@ func TestCmd_ObjectWithArrayMember(t *testing.T) {
 	`
 
 	testCases := []TestCase{
-		{"content", "\"[{\"id\": 0,\"word\": \"velit\"},{\"id\": 1,\"word\": \"culpa\"},{\"id\": 2,\"word\": \"pariatur\"}]\""},
+		{"content", `[
+  {
+    "id": 0,
+    "word": "velit"
+  },
+  {
+    "id": 1,
+    "word": "culpa"
+  },
+  {
+    "id": 2,
+    "word": "pariatur"
+  }
+]`},
 	}
 
 	for i := 0; i < len(testCases); i++ {
@@ -97,6 +114,7 @@ func TestCmd_ObjectWithArrayMember(t *testing.T) {
 			inputFiles: nil,
 			nth:        "",
 			compact:    false,
+			values:     true,
 		}
 		runRootCmd(&vars, nil, nil)
 
@@ -123,13 +141,13 @@ func TestCmd_Numerical_Formatting(t *testing.T) {
 
 	testCases := []TestCase{
 		{"α", "0.0072973525693"},
-		{"γ", "0.5772156649015329"},
-		{"δ", "4.66920160910299"},
-		{"ϵ", "8854187812813"},
-		{"ζ", "1.2020569031595942"},
+		{"γ", "0.5772156649015328606065120900824024310421"},
+		{"δ", "4.669201609102990671853203820466"},
+		{"ϵ", "8.854187812813e12"},
+		{"ζ", "1.202056903159594285399738161511449990764986292"},
 		{"θ", "90"},
-		{"μ", "1.2566370614e-06"},
-		{"ψ", "3.3598856662431777"},
+		{"μ", "1.2566370614E-6"},
+		{"ψ", "3.359885666243177553172011302918927179688905133732"},
 	}
 
 	for i := 0; i < len(testCases); i++ {
@@ -143,6 +161,7 @@ func TestCmd_Numerical_Formatting(t *testing.T) {
 			inputFiles: nil,
 			nth:        "",
 			compact:    false,
+			values:     true,
 		}
 		runRootCmd(&vars, nil, nil)
 
@@ -224,6 +243,7 @@ func TestCmd_StdIn_NestedObject(t *testing.T) {
 		inputFiles: nil,
 		nth:        "",
 		compact:    false,
+		values:     true,
 	}
 	runRootCmd(&vars, nil, nil)
 
@@ -322,6 +342,7 @@ func TestCmd_StdIn_SelectFromSubArray(t *testing.T) {
 		inputFiles: nil,
 		nth:        "",
 		compact:    false,
+		values:     true,
 	}
 	runRootCmd(&vars, nil, nil)
 
@@ -419,6 +440,7 @@ func TestCmd_StdIn_SelectFromSubArray_DuplicateColumns(t *testing.T) {
 		inputFiles: nil,
 		nth:        "",
 		compact:    false,
+		values:     true,
 	}
 	runRootCmd(&vars, nil, nil)
 
@@ -503,6 +525,7 @@ func TestCmd_StdIn_JoinSubArrays(t *testing.T) {
 			inputFiles: nil,
 			nth:        "",
 			compact:    false,
+			values:     true,
 		}
 		runRootCmd(&vars, nil, nil)
 
@@ -580,6 +603,7 @@ func TestCmd_StdIn_SelfJoin(t *testing.T) {
 			inputFiles: nil,
 			nth:        "",
 			compact:    false,
+			values:     true,
 		}
 		runRootCmd(&vars, nil, nil)
 
@@ -671,6 +695,7 @@ func TestCmd_StdIn_MoreJoin(t *testing.T) {
 			inputFiles: nil,
 			nth:        "",
 			compact:    false,
+			values:     true,
 		}
 		runRootCmd(&vars, nil, nil)
 
@@ -690,3 +715,2015 @@ func TestCmd_StdIn_MoreJoin(t *testing.T) {
 		}
 	}
 }
+
+func TestCmd_StdIn_NewlineDelimited(t *testing.T) {
+	// Arrange.
+	json := `{"id": 1, "tags": ["a", "b"], "about": {"metric": 2.5}}
+{"id": 2, "tags": ["c"], "about": {"metric": -1}}
+
+{"id": 3, "tags": [], "about": {"metric": 7}}
+`
+
+	type TestCase struct {
+		statement string
+		expected  []string
+	}
+	cases := []TestCase{
+		{
+			"SELECT id FROM [] WHERE about$metric > 0",
+			[]string{"1", "3"},
+		},
+		{
+			"SELECT COUNT(*) FROM []",
+			[]string{"3"},
+		},
+	}
+
+	for i, test := range cases {
+		vtable.Driver = fmt.Sprintf("TestCmd_StdIn_NewlineDelimited_%d", i)
+		ioIn = bytes.NewReader([]byte(json))
+		ioOut = bytes.NewBuffer(nil)
+		ioErr = bytes.NewBuffer(nil)
+
+		// Act.
+		vars := rootCmdVars{
+			query:      test.statement,
+			inputFiles: nil,
+			nth:        "",
+			compact:    false,
+			values:     true,
+			lines:      true,
+		}
+		if err := runRootCmd(&vars, nil, nil); err != nil {
+			t.Fatalf("unexpected error for %q: %v", test.statement, err)
+		}
+
+		// Assert.
+		result := ioOut.(*bytes.Buffer).String()
+		result = strings.Trim(result, "\n")
+
+		splitResult := strings.Split(result, "\n")
+		if len(splitResult) != len(test.expected) {
+			t.Error("unexpected number of values")
+		}
+
+		for i, value := range splitResult {
+			if strings.Trim(value, "\n") != test.expected[i] {
+				t.Error("unexpected values")
+			}
+		}
+	}
+}
+
+func TestCmd_StdIn_ConcatenatedDocuments(t *testing.T) {
+	// Arrange.
+	json := `{"id": 1, "word": "velit"}{"id": 2, "word": "culpa"} {"id": 3, "word": "pariatur"}`
+
+	type TestCase struct {
+		statement string
+		expected  []string
+	}
+	cases := []TestCase{
+		{
+			"SELECT word FROM [] WHERE id > 1",
+			[]string{"\"culpa\"", "\"pariatur\""},
+		},
+		{
+			"SELECT COUNT(*) FROM []",
+			[]string{"3"},
+		},
+	}
+
+	for i, test := range cases {
+		vtable.Driver = fmt.Sprintf("TestCmd_StdIn_ConcatenatedDocuments_%d", i)
+		ioIn = bytes.NewReader([]byte(json))
+		ioOut = bytes.NewBuffer(nil)
+		ioErr = bytes.NewBuffer(nil)
+
+		// Act.
+		vars := rootCmdVars{
+			query:      test.statement,
+			inputFiles: nil,
+			nth:        "",
+			compact:    false,
+			values:     true,
+		}
+		if err := runRootCmd(&vars, nil, nil); err != nil {
+			t.Fatalf("unexpected error for %q: %v", test.statement, err)
+		}
+
+		// Assert.
+		result := ioOut.(*bytes.Buffer).String()
+		result = strings.Trim(result, "\n")
+
+		splitResult := strings.Split(result, "\n")
+		if len(splitResult) != len(test.expected) {
+			t.Error("unexpected number of values")
+		}
+
+		for i, value := range splitResult {
+			if strings.Trim(value, "\n") != test.expected[i] {
+				t.Error("unexpected values")
+			}
+		}
+	}
+}
+
+func TestCmd_StdIn_ConcatenatedArrays(t *testing.T) {
+	// Arrange.
+	json := "[1, 2]\n[3, 4]\n"
+
+	for i, lines := range []bool{false, true} {
+		vtable.Driver = fmt.Sprintf("TestCmd_StdIn_ConcatenatedArrays_%d", i)
+		ioIn = bytes.NewReader([]byte(json))
+		ioOut = bytes.NewBuffer(nil)
+		ioErr = bytes.NewBuffer(nil)
+
+		// Act.
+		vars := rootCmdVars{
+			query:      "SELECT value FROM []",
+			inputFiles: nil,
+			compact:    true,
+			lines:      lines,
+			values:     true,
+		}
+		if err := runRootCmd(&vars, nil, nil); err != nil {
+			t.Fatalf("unexpected error with lines %t: %v", lines, err)
+		}
+
+		// Assert.
+		expected := "[1,2]\n[3,4]\n"
+		if result := ioOut.(*bytes.Buffer).String(); result != expected {
+			t.Errorf("lines %t: expected %q, got %q", lines, expected, result)
+		}
+	}
+}
+
+func TestCmd_ExactNumbers(t *testing.T) {
+	// Arrange.
+	json := `[
+		{"id": 1234567890123456789, "count": 42, "price": 1.50, "about": {"id": 12345678901234567890123, "ratio": 0.10}},
+		{"id": 1234567890123456790, "count": 7, "price": 2.25, "about": {"id": 1, "ratio": 1e400}}
+	]`
+
+	type TestCase struct {
+		statement string
+		expected  []string
+	}
+	cases := []TestCase{
+		{
+			"SELECT id FROM []",
+			[]string{"1234567890123456789", "1234567890123456790"},
+		},
+		{
+			"SELECT count FROM [] WHERE id = 1234567890123456790",
+			[]string{"7"},
+		},
+		{
+			"SELECT typeof(count), typeof(price) FROM [] WHERE count = 42",
+			[]string{`"integer"`, `"real"`},
+		},
+		{
+			"SELECT about FROM []",
+			[]string{
+				`{"id": 12345678901234567890123,"ratio": 0.10}`,
+				`{"id": 1,"ratio": 1e400}`,
+			},
+		},
+		{
+			"SELECT price, about.id, about.ratio FROM []",
+			[]string{"1.50", "12345678901234567890123", "0.10", "2.25", "1", "1e400"},
+		},
+		{
+			"SELECT price * 2, about.ratio + 1 FROM [] WHERE count = 7",
+			[]string{"4.5", "null"},
+		},
+	}
+
+	for i, test := range cases {
+		vtable.Driver = fmt.Sprintf("TestCmd_ExactNumbers_%d", i)
+		ioIn = bytes.NewReader([]byte(json))
+		ioOut = bytes.NewBuffer(nil)
+		ioErr = bytes.NewBuffer(nil)
+
+		// Act.
+		vars := rootCmdVars{
+			query:      test.statement,
+			inputFiles: nil,
+			nth:        "",
+			compact:    true,
+			values:     true,
+		}
+		if err := runRootCmd(&vars, nil, nil); err != nil {
+			t.Fatalf("unexpected error for %q: %v", test.statement, err)
+		}
+
+		// Assert.
+		result := ioOut.(*bytes.Buffer).String()
+		result = strings.Trim(result, "\n")
+
+		splitResult := strings.Split(result, "\n")
+		if len(splitResult) != len(test.expected) {
+			t.Fatalf("unexpected number of values")
+		}
+
+		for i, value := range splitResult {
+			if value != test.expected[i] {
+				t.Errorf("expected %s, got %s", test.expected[i], value)
+			}
+		}
+	}
+}
+
+func TestCmd_TypedResults(t *testing.T) {
+	// Arrange.
+	json := `[
+		{"id": 1, "code": "007", "active": true, "note": null, "about": {"tags": ["a", "b"]}, "mixed": 1},
+		{"id": 2, "code": "1e3", "active": false, "about": {"tags": []}, "mixed": true}
+	]`
+
+	type TestCase struct {
+		statement string
+		expected  []string
+	}
+	cases := []TestCase{
+		{
+			"SELECT code FROM []",
+			[]string{`"007"`, `"1e3"`},
+		},
+		{
+			"SELECT active FROM [] ORDER BY id DESC",
+			[]string{"false", "true"},
+		},
+		{
+			"SELECT id FROM [] WHERE active",
+			[]string{"1"},
+		},
+		{
+			"SELECT note FROM []",
+			[]string{"null", "null"},
+		},
+		{
+			"SELECT a FROM (SELECT about AS a, id FROM []) WHERE id = 1",
+			[]string{`{"tags": ["a","b"]}`},
+		},
+		{
+			"SELECT mixed FROM []",
+			[]string{"1", "true"},
+		},
+		{
+			"SELECT mixed FROM [] ORDER BY id DESC",
+			[]string{"1", "1"},
+		},
+		{
+			"SELECT mixed FROM [] WHERE id = 2",
+			[]string{"true"},
+		},
+		{
+			"SELECT code || '!', id * 1.5, active = 1 FROM [] WHERE id = 1",
+			[]string{`"007!"`, "1.5", "1"},
+		},
+	}
+
+	for i, test := range cases {
+		vtable.Driver = fmt.Sprintf("TestCmd_TypedResults_%d", i)
+		ioIn = bytes.NewReader([]byte(json))
+		ioOut = bytes.NewBuffer(nil)
+		ioErr = bytes.NewBuffer(nil)
+
+		// Act.
+		vars := rootCmdVars{
+			query:      test.statement,
+			inputFiles: nil,
+			nth:        "",
+			compact:    true,
+			values:     true,
+		}
+		if err := runRootCmd(&vars, nil, nil); err != nil {
+			t.Fatalf("unexpected error for %q: %v", test.statement, err)
+		}
+
+		// Assert.
+		result := ioOut.(*bytes.Buffer).String()
+		result = strings.Trim(result, "\n")
+
+		splitResult := strings.Split(result, "\n")
+		if len(splitResult) != len(test.expected) {
+			t.Fatalf("unexpected number of values: %q", result)
+		}
+
+		for i, value := range splitResult {
+			if value != test.expected[i] {
+				t.Errorf("expected %s, got %s", test.expected[i], value)
+			}
+		}
+	}
+}
+
+func TestCmd_StringEscapes(t *testing.T) {
+	// Arrange.
+	json := `[
+		{"id": 1, "name": "caf\u00e9", "quote": "she said \"hi\"\n\tthen left", "keys": {"": 1, "\"": 2}},
+		{"id": 2, "name": "\ud83d\ude00", "quote": "C:\\temp\/file"}
+	]`
+
+	type TestCase struct {
+		statement string
+		expected  []string
+	}
+	cases := []TestCase{
+		{
+			"SELECT quote FROM []",
+			[]string{`"she said \"hi\"\n\tthen left"`, `"C:\\temp/file"`},
+		},
+		{
+			"SELECT id FROM [] WHERE 'café' = name",
+			[]string{"1"},
+		},
+		{
+			"SELECT name FROM [] WHERE id = 2",
+			[]string{`"😀"`},
+		},
+		{
+			"SELECT keys FROM [] WHERE id = 1",
+			[]string{"{", `  "": 1,`, `  "\"": 2`, "}"},
+		},
+	}
+
+	for i, test := range cases {
+		vtable.Driver = fmt.Sprintf("TestCmd_StringEscapes_%d", i)
+		ioIn = bytes.NewReader([]byte(json))
+		ioOut = bytes.NewBuffer(nil)
+		ioErr = bytes.NewBuffer(nil)
+
+		// Act.
+		vars := rootCmdVars{
+			query:      test.statement,
+			inputFiles: nil,
+			nth:        "",
+			compact:    false,
+			values:     true,
+		}
+		if err := runRootCmd(&vars, nil, nil); err != nil {
+			t.Fatalf("unexpected error for %q: %v", test.statement, err)
+		}
+
+		// Assert.
+		result := ioOut.(*bytes.Buffer).String()
+		result = strings.Trim(result, "\n")
+
+		splitResult := strings.Split(result, "\n")
+		if len(splitResult) != len(test.expected) {
+			t.Fatalf("unexpected number of values")
+		}
+
+		for i, value := range splitResult {
+			if value != test.expected[i] {
+				t.Errorf("expected %s, got %s", test.expected[i], value)
+			}
+		}
+	}
+}
+
+func TestCmd_Rows(t *testing.T) {
+	// Arrange.
+	json := `[
+		{"id": 1, "name": "velit", "about": {"score": 0.5}},
+		{"id": 2, "name": "culpa", "about": {"score": 1.5}}
+	]`
+
+	type TestCase struct {
+		statement string
+		compact   bool
+		expected  string
+	}
+	cases := []TestCase{
+		{
+			"SELECT id, name FROM []",
+			true,
+			`{"id": 1,"name": "velit"}
+{"id": 2,"name": "culpa"}`,
+		},
+		{
+			"SELECT name AS word, id * 2 doubled, about FROM [] WHERE id = 2",
+			false,
+			`{
+  "word": "culpa",
+  "doubled": 4,
+  "about": {
+    "score": 1.5
+  }
+}`,
+		},
+		{
+			"SELECT id, id, id AS \"id:1\" FROM [] WHERE id = 1",
+			true,
+			`{"id": 1,"id:1": 1,"id:1:1": 1}`,
+		},
+		{
+			"SELECT COUNT(*) FROM []",
+			true,
+			`{"COUNT(*)": 2}`,
+		},
+	}
+
+	for i, test := range cases {
+		vtable.Driver = fmt.Sprintf("TestCmd_Rows_%d", i)
+		ioIn = bytes.NewReader([]byte(json))
+		ioOut = bytes.NewBuffer(nil)
+		ioErr = bytes.NewBuffer(nil)
+
+		// Act.
+		vars := rootCmdVars{
+			query:      test.statement,
+			inputFiles: nil,
+			nth:        "",
+			compact:    test.compact,
+		}
+		if err := runRootCmd(&vars, nil, nil); err != nil {
+			t.Fatalf("unexpected error for %q: %v", test.statement, err)
+		}
+
+		// Assert.
+		result := ioOut.(*bytes.Buffer).String()
+		if strings.Trim(result, "\n") != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, result)
+		}
+	}
+}
+
+func TestCmd_SelectStar(t *testing.T) {
+	// Arrange.
+	json := `[
+		{"id": 1, "word": "velit", "content": [{"n": 1}, {"n": 2, "m": true}]},
+		{"id": 2, "extra": false, "content": [], "tags": ["a", [1]]}
+	]`
+
+	type TestCase struct {
+		statement string
+		expected  []string
+	}
+	cases := []TestCase{
+		{
+			"SELECT * FROM []",
+			[]string{
+				`{"id": 1,"word": "velit","content": [{"n": 1},{"n": 2,"m": true}],"extra": null,"tags": null}`,
+				`{"id": 2,"word": null,"content": [],"extra": false,"tags": ["a",[1]]}`,
+			},
+		},
+		{
+			"SELECT * FROM [] WHERE coalesce(missing, id) = 2",
+			[]string{`{"id": 2,"word": null,"content": [],"extra": false,"tags": ["a",[1]],"missing": null}`},
+		},
+		{
+			"SELECT * FROM tags",
+			[]string{`{"value": "a"}`, `{"value": [1]}`},
+		},
+		{
+			"SELECT c.* FROM content AS c",
+			[]string{`{"n": 1,"m": null}`, `{"n": 2,"m": true}`},
+		},
+		{
+			"SELECT COUNT(*) AS count FROM []",
+			[]string{`{"count": 2}`},
+		},
+	}
+
+	for i, test := range cases {
+		vtable.Driver = fmt.Sprintf("TestCmd_SelectStar_%d", i)
+		ioIn = bytes.NewReader([]byte(json))
+		ioOut = bytes.NewBuffer(nil)
+		ioErr = bytes.NewBuffer(nil)
+
+		// Act.
+		vars := rootCmdVars{
+			query:      test.statement,
+			inputFiles: nil,
+			nth:        "",
+			compact:    true,
+		}
+		if err := runRootCmd(&vars, nil, nil); err != nil {
+			t.Fatalf("unexpected error for %q: %v", test.statement, err)
+		}
+
+		// Assert.
+		result := ioOut.(*bytes.Buffer).String()
+		result = strings.Trim(result, "\n")
+
+		splitResult := strings.Split(result, "\n")
+		if len(splitResult) != len(test.expected) {
+			t.Fatalf("unexpected number of rows: %q", result)
+		}
+
+		for j, value := range splitResult {
+			if value != test.expected[j] {
+				t.Errorf("expected %s, got %s", test.expected[j], value)
+			}
+		}
+	}
+}
+
+func TestCmd_HiddenColumns(t *testing.T) {
+	// Arrange.
+	json := `[
+		{"id": 1, "name": "", "content": [{"word": "velit"}, {"word": "culpa"}]},
+		{"id": 2, "content": {"word": "irure"}},
+		{"id": 3, "_index": "shadowed"}
+	]`
+
+	type TestCase struct {
+		statement string
+		nth       string
+		expected  []string
+	}
+	cases := []TestCase{
+		{
+			"SELECT id, _index, _path, _parent_index FROM []",
+			"",
+			[]string{
+				`{"id": 1,"_index": 0,"_path": "","_parent_index": null}`,
+				`{"id": 2,"_index": 1,"_path": "","_parent_index": null}`,
+				`{"id": 3,"_index": 2,"_path": "","_parent_index": null}`,
+			},
+		},
+		{
+			"SELECT _index, _path, _parent_index FROM content",
+			"",
+			[]string{
+				`{"_index": 0,"_path": "/content/0","_parent_index": 0}`,
+				`{"_index": 1,"_path": "/content/1","_parent_index": 0}`,
+				`{"_index": null,"_path": "/content","_parent_index": 1}`,
+			},
+		},
+		{
+			"SELECT p.id, c.word FROM [] AS p JOIN content AS c ON c._parent_index = p._index WHERE c._index > 0",
+			"",
+			[]string{`{"id": 1,"word": "culpa"}`},
+		},
+		{
+			"SELECT _raw, name FROM [] WHERE id = 1",
+			"",
+			[]string{`{"_raw": {"id": 1,"name": "","content": [{"word": "velit"},{"word": "culpa"}]},"name": ""}`},
+		},
+		{
+			"SELECT _raw FROM content WHERE _parent_index = 1",
+			"",
+			[]string{`{"_raw": {"word": "irure"}}`},
+		},
+		{
+			"SELECT id, _index FROM []",
+			"-2",
+			[]string{`{"id": 2,"_index": 1}`},
+		},
+		{
+			"SELECT * FROM [] WHERE id = 3",
+			"",
+			[]string{`{"id": 3,"name": null,"content": null,"content$word": null}`},
+		},
+	}
+
+	for i, test := range cases {
+		vtable.Driver = fmt.Sprintf("TestCmd_HiddenColumns_%d", i)
+		ioIn = bytes.NewReader([]byte(json))
+		ioOut = bytes.NewBuffer(nil)
+		ioErr = bytes.NewBuffer(nil)
+
+		// Act.
+		vars := rootCmdVars{
+			query:      test.statement,
+			inputFiles: nil,
+			nth:        test.nth,
+			compact:    true,
+		}
+		if err := runRootCmd(&vars, nil, nil); err != nil {
+			t.Fatalf("unexpected error for %q: %v", test.statement, err)
+		}
+
+		// Assert.
+		result := strings.Trim(ioOut.(*bytes.Buffer).String(), "\n")
+		splitResult := strings.Split(result, "\n")
+		if len(splitResult) != len(test.expected) {
+			t.Fatalf("unexpected number of rows for %q: %q", test.statement, result)
+		}
+
+		for j, value := range splitResult {
+			if value != test.expected[j] {
+				t.Errorf("expected %s, got %s", test.expected[j], value)
+			}
+		}
+	}
+}
+
+func TestCmd_NestedTables(t *testing.T) {
+	// Arrange.
+	json := `[
+		{"id": 1, "orders": [{"no": "a"}, {"no": "b", "shipments": [{"packages": [{"w": 1}, {"w": 2}]}, {"packages": {"w": 3}}]}], "about": {"score": 5}},
+		{"id": 2, "orders": [{"no": "c", "shipments": [{}, {"packages": [{"w": 4}]}]}]},
+		{"id": 3, "orders": {"no": "d", "shipments": []}, "about": {"score": 7}, "a.b": [{"x": 1}], "say \"hi\"": {"x": 2}}
+	]`
+
+	type TestCase struct {
+		statement string
+		expected  []string
+	}
+	cases := []TestCase{
+		{
+			"SELECT w, _index, _path, _parent_index FROM orders$shipments$packages",
+			[]string{
+				`{"w": 1,"_index": 0,"_path": "/orders/1/shipments/0/packages/0","_parent_index": 0}`,
+				`{"w": 2,"_index": 1,"_path": "/orders/1/shipments/0/packages/1","_parent_index": 0}`,
+				`{"w": 3,"_index": null,"_path": "/orders/1/shipments/1/packages","_parent_index": 0}`,
+				`{"w": 4,"_index": 0,"_path": "/orders/0/shipments/1/packages/0","_parent_index": 1}`,
+			},
+		},
+		{
+			"SELECT * FROM orders$shipments$packages",
+			[]string{`{"w": 1}`, `{"w": 2}`, `{"w": 3}`, `{"w": 4}`},
+		},
+		{
+			"SELECT p.id, o.no FROM [] AS p JOIN orders AS o ON o._parent_index = p._index",
+			[]string{
+				`{"id": 1,"no": "a"}`,
+				`{"id": 1,"no": "b"}`,
+				`{"id": 2,"no": "c"}`,
+				`{"id": 3,"no": "d"}`,
+			},
+		},
+		{
+			"SELECT score, _path FROM about",
+			[]string{
+				`{"score": 5,"_path": "/about"}`,
+				`{"score": 7,"_path": "/about"}`,
+			},
+		},
+		{
+			"SELECT * FROM about",
+			[]string{`{"score": 5}`, `{"score": 7}`},
+		},
+		{
+			"SELECT x FROM \"a.b\" UNION ALL SELECT x FROM \"say \"\"hi\"\"\"",
+			[]string{`{"x": 1}`, `{"x": 2}`},
+		},
+	}
+
+	for i, test := range cases {
+		vtable.Driver = fmt.Sprintf("TestCmd_NestedTables_%d", i)
+		ioIn = bytes.NewReader([]byte(json))
+		ioOut = bytes.NewBuffer(nil)
+		ioErr = bytes.NewBuffer(nil)
+
+		// Act.
+		vars := rootCmdVars{
+			query:      test.statement,
+			inputFiles: nil,
+			compact:    true,
+		}
+		if err := runRootCmd(&vars, nil, nil); err != nil {
+			t.Fatalf("unexpected error for %q: %v", test.statement, err)
+		}
+
+		// Assert.
+		result := strings.Trim(ioOut.(*bytes.Buffer).String(), "\n")
+		splitResult := strings.Split(result, "\n")
+		if len(splitResult) != len(test.expected) {
+			t.Fatalf("unexpected number of rows for %q: %q", test.statement, result)
+		}
+
+		for j, value := range splitResult {
+			if value != test.expected[j] {
+				t.Errorf("expected %s, got %s", test.expected[j], value)
+			}
+		}
+	}
+}
+
+func TestCmd_Paths(t *testing.T) {
+	// Arrange.
+	json := `[
+		{"id": 1, "about": {"metric": 5, "a.b": 1}, "tags": ["x", "y"], "items": [{"price": 3}, {"price": 9}], "weird key": {"x": true}, "a.b": 7},
+		{"id": 2, "about": {"metric": 6}, "tags": [], "items": []}
+	]`
+
+	type TestCase struct {
+		statement string
+		expected  []string
+	}
+	cases := []TestCase{
+		{
+			"SELECT about.metric, tags[0], items[-1].price, \"weird key\".x FROM []",
+			[]string{
+				`{"about.metric": 5,"tags[0]": "x","items[-1].price": 9,"weird key.x": true}`,
+				`{"about.metric": 6,"tags[0]": null,"items[-1].price": null,"weird key.x": null}`,
+			},
+		},
+		{
+			"SELECT p.id, p.about.metric AS m FROM [] AS p WHERE p.tags[1] = 'y'",
+			[]string{`{"id": 1,"m": 5}`},
+		},
+		{
+			"SELECT \"a.b\", about.\"a.b\" AS c, about$metric FROM [] WHERE id = 1",
+			[]string{`{"a.b": 7,"c": 1,"about$metric": 5}`},
+		},
+		{
+			"SELECT * FROM [] WHERE about.metric > 5",
+			[]string{`{"id": 2,"about": {"metric": 6},"about$metric": 6,"about$a.b": null,"tags": [],"items": [],"weird key": null,"weird key$x": null,"a.b": null}`},
+		},
+		{
+			"SELECT id, 'tags' AS t FROM [] WHERE id = 'x' OR id > 1",
+			[]string{`{"id": 2,"t": "tags"}`},
+		},
+		{
+			"SELECT id FROM [] WHERE \"weird key\".x AND tags[0] = \"x\"",
+			[]string{`{"id": 1}`},
+		},
+		{
+			"SELECT v.value FROM [] AS o, each(o.items, '[1].price') AS v",
+			[]string{`{"value": 9}`},
+		},
+	}
+
+	for i, test := range cases {
+		vtable.Driver = fmt.Sprintf("TestCmd_Paths_%d", i)
+		ioIn = bytes.NewReader([]byte(json))
+		ioOut = bytes.NewBuffer(nil)
+		ioErr = bytes.NewBuffer(nil)
+
+		// Act.
+		vars := rootCmdVars{
+			query:      test.statement,
+			inputFiles: nil,
+			compact:    true,
+		}
+		if err := runRootCmd(&vars, nil, nil); err != nil {
+			t.Fatalf("unexpected error for %q: %v", test.statement, err)
+		}
+
+		// Assert.
+		result := strings.Trim(ioOut.(*bytes.Buffer).String(), "\n")
+		splitResult := strings.Split(result, "\n")
+		if len(splitResult) != len(test.expected) {
+			t.Fatalf("unexpected number of rows for %q: %q", test.statement, result)
+		}
+
+		for j, value := range splitResult {
+			if value != test.expected[j] {
+				t.Errorf("expected %s, got %s", test.expected[j], value)
+			}
+		}
+	}
+}
+
+func TestCmd_Each(t *testing.T) {
+	// Arrange.
+	json := `[
+		{"id": 1, "items": [{"sku": "a", "n": 2}, {"sku": "b", "n": 1}], "tags": ["x", true], "about": {"k": 1, "v": [1]}},
+		{"id": 2, "items": [], "tags": "solo", "about": null}
+	]`
+
+	type TestCase struct {
+		statement string
+		expected  []string
+	}
+	cases := []TestCase{
+		{
+			"SELECT o.id, i.value FROM [] AS o, each(o.items) AS i",
+			[]string{
+				`{"id": 1,"value": {"sku": "a","n": 2}}`,
+				`{"id": 1,"value": {"sku": "b","n": 1}}`,
+			},
+		},
+		{
+			"SELECT o.id, i.sku FROM [] o, each(o.items) i",
+			[]string{
+				`{"id": 1,"sku": "a"}`,
+				`{"id": 1,"sku": "b"}`,
+			},
+		},
+		{
+			"SELECT i.value.sku, i.n FROM [] AS o, each(o.items) AS i WHERE i.value.n > 1",
+			[]string{`{"value.sku": "a","n": 2}`},
+		},
+		{
+			"SELECT o.id, t.key, t.value, t.type, t.\"index\" FROM [] AS o, unnest(o.tags) AS t",
+			[]string{
+				`{"id": 1,"key": 0,"value": "x","type": "string","index": 0}`,
+				`{"id": 1,"key": 1,"value": true,"type": "boolean","index": 1}`,
+				`{"id": 2,"key": null,"value": "solo","type": "string","index": 0}`,
+			},
+		},
+		{
+			"SELECT m.key, m.value FROM [] AS o, each(o.about) AS m",
+			[]string{
+				`{"key": "k","value": 1}`,
+				`{"key": "v","value": [1]}`,
+			},
+		},
+		{
+			"SELECT v.value FROM [] AS o, each(o.about, 'v') AS v ORDER BY v.value",
+			[]string{`{"value": 1}`},
+		},
+		{
+			"SELECT o.id, i.key FROM [] AS o LEFT JOIN each(o.items) AS i",
+			[]string{
+				`{"id": 1,"key": 0}`,
+				`{"id": 1,"key": 1}`,
+				`{"id": 2,"key": null}`,
+			},
+		},
+		{
+			"SELECT e.key AS item, f.key, f.value FROM [] AS o, each(o.items) AS e, each(e.value) AS f WHERE f.key = 'n' ORDER BY e.key",
+			[]string{
+				`{"item": 0,"key": "n","value": 2}`,
+				`{"item": 1,"key": "n","value": 1}`,
+			},
+		},
+		{
+			"SELECT value, key FROM each(5)",
+			[]string{`{"value": 5,"key": null}`},
+		},
+		{
+			"SELECT o.id, e.value FROM each('[1,2]') AS e, [] AS o",
+			[]string{
+				`{"id": 1,"value": 1}`,
+				`{"id": 2,"value": 1}`,
+				`{"id": 1,"value": 2}`,
+				`{"id": 2,"value": 2}`,
+			},
+		},
+		{
+			"SELECT o.id, u.value FROM unnest('[\"x\",\"y\"]') AS u JOIN [] AS o ON o.id = 2 ORDER BY u.value",
+			[]string{`{"id": 2,"value": "x"}`, `{"id": 2,"value": "y"}`},
+		},
+	}
+
+	for i, test := range cases {
+		vtable.Driver = fmt.Sprintf("TestCmd_Each_%d", i)
+		ioIn = bytes.NewReader([]byte(json))
+		ioOut = bytes.NewBuffer(nil)
+		ioErr = bytes.NewBuffer(nil)
+
+		// Act.
+		vars := rootCmdVars{
+			query:      test.statement,
+			inputFiles: nil,
+			compact:    true,
+		}
+		if err := runRootCmd(&vars, nil, nil); err != nil {
+			t.Fatalf("unexpected error for %q: %v", test.statement, err)
+		}
+
+		// Assert.
+		result := strings.Trim(ioOut.(*bytes.Buffer).String(), "\n")
+		splitResult := strings.Split(result, "\n")
+		if len(splitResult) != len(test.expected) {
+			t.Fatalf("unexpected number of rows for %q: %q", test.statement, result)
+		}
+
+		for j, value := range splitResult {
+			if value != test.expected[j] {
+				t.Errorf("expected %s, got %s", test.expected[j], value)
+			}
+		}
+	}
+}
+
+func TestCmd_Constraints(t *testing.T) {
+	// Arrange.
+	json := `[
+		{"id": 1, "name": "velit", "score": 2.5},
+		{"id": "1", "name": "Culpa", "score": 2},
+		{"id": 2.0, "name": "culpa", "score": null, "parent": 1},
+		{"id": 9007199254740993, "name": "irure", "score": true, "parent": 2}
+	]`
+
+	type TestCase struct {
+		statement string
+		expected  []string
+	}
+	cases := []TestCase{
+		{
+			"SELECT name FROM [] WHERE id = 1",
+			[]string{`{"name": "velit"}`},
+		},
+		{
+			"SELECT name FROM [] WHERE '1' = id",
+			[]string{`{"name": "Culpa"}`},
+		},
+		{
+			"SELECT name FROM [] WHERE id = 2",
+			[]string{`{"name": "culpa"}`},
+		},
+		{
+			"SELECT name FROM [] WHERE id = 9007199254740993",
+			[]string{`{"name": "irure"}`},
+		},
+		{
+			"SELECT name FROM [] WHERE id = 9007199254740992",
+			[]string{},
+		},
+		{
+			"SELECT name FROM [] WHERE id > 1",
+			[]string{`{"name": "Culpa"}`, `{"name": "culpa"}`, `{"name": "irure"}`},
+		},
+		{
+			"SELECT name FROM [] WHERE score >= 2 AND score < 3",
+			[]string{`{"name": "velit"}`, `{"name": "Culpa"}`},
+		},
+		{
+			"SELECT name FROM [] WHERE score = 1",
+			[]string{`{"name": "irure"}`},
+		},
+		{
+			"SELECT name FROM [] WHERE name > 'a' AND name <= 'culpa' ORDER BY id",
+			[]string{`{"name": "culpa"}`},
+		},
+		{
+			"SELECT c.name AS child, p.name AS parent FROM [] AS c JOIN [] AS p ON c.parent = p.id",
+			[]string{`{"child": "culpa","parent": "velit"}`, `{"child": "irure","parent": "culpa"}`},
+		},
+		{
+			"SELECT name FROM (SELECT 1 AS z UNION ALL SELECT 2) AS v JOIN [] ON v.z = id",
+			[]string{`{"name": "velit"}`, `{"name": "culpa"}`},
+		},
+		{
+			"SELECT name FROM [] WHERE id = 1 OR id = 2",
+			[]string{`{"name": "velit"}`, `{"name": "culpa"}`},
+		},
+		{
+			"SELECT name FROM [] WHERE id IN (1, 2)",
+			[]string{`{"name": "velit"}`, `{"name": "culpa"}`},
+		},
+		{
+			"SELECT name FROM [] WHERE CAST(id AS INTEGER) = 1",
+			[]string{`{"name": "velit"}`, `{"name": "Culpa"}`},
+		},
+		{
+			"SELECT name FROM [] WHERE name = 'CULPA' COLLATE NOCASE",
+			[]string{`{"name": "Culpa"}`, `{"name": "culpa"}`},
+		},
+	}
+
+	for i, test := range cases {
+		vtable.Driver = fmt.Sprintf("TestCmd_Constraints_%d", i)
+		ioIn = bytes.NewReader([]byte(json))
+		ioOut = bytes.NewBuffer(nil)
+		ioErr = bytes.NewBuffer(nil)
+
+		// Act.
+		vars := rootCmdVars{
+			query:      test.statement,
+			inputFiles: nil,
+			nth:        "",
+			compact:    true,
+		}
+		if err := runRootCmd(&vars, nil, nil); err != nil {
+			t.Fatalf("unexpected error for %q: %v", test.statement, err)
+		}
+
+		// Assert.
+		result := strings.Trim(ioOut.(*bytes.Buffer).String(), "\n")
+		splitResult := strings.Split(result, "\n")
+		if result == "" {
+			splitResult = nil
+		}
+		if len(splitResult) != len(test.expected) {
+			t.Fatalf("unexpected number of rows for %q: %q", test.statement, result)
+		}
+
+		for j, value := range splitResult {
+			if value != test.expected[j] {
+				t.Errorf("expected %s, got %s", test.expected[j], value)
+			}
+		}
+	}
+}
+
+func TestCmd_Schema(t *testing.T) {
+	// Arrange.
+	json := `[
+		{"id": 1, "name": "velit", "about": {"score": 0.5}},
+		{"id": "2", "name": null}
+	]`
+
+	type TestCase struct {
+		json     bool
+		expected string
+	}
+	cases := []TestCase{
+		{
+			false,
+			`COLUMN       TYPES                   PRESENT  NULL  EXAMPLES
+id           number (1), string (1)  2/2      0     1, "2"
+name         string                  2/2      1     "velit"
+about        object                  1/2      0     {"score": 0.5}
+about$score  number                  1/2      0     0.5`,
+		},
+		{
+			true,
+			`{"rows": 2,"columns": [` +
+				`{"name": "id","types": {"number": 1,"string": 1},"present": 2,"null": 0,"examples": [1,"2"]},` +
+				`{"name": "name","types": {"string": 1,"null": 1},"present": 2,"null": 1,"examples": ["velit"]},` +
+				`{"name": "about","types": {"object": 1},"present": 1,"null": 0,"examples": [{"score": 0.5}]},` +
+				`{"name": "about$score","types": {"number": 1},"present": 1,"null": 0,"examples": [0.5]}]}`,
+		},
+	}
+
+	for _, test := range cases {
+		ioIn = bytes.NewReader([]byte(json))
+		ioOut = bytes.NewBuffer(nil)
+		ioErr = bytes.NewBuffer(nil)
+
+		// Act.
+		vars := schemaCmdVars{
+			inputFiles: nil,
+			json:       test.json,
+		}
+		err := runSchemaCmd(&vars)
+
+		// Assert.
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		result := strings.Trim(ioOut.(*bytes.Buffer).String(), "\n")
+		if test.json {
+			// Compare against the compact form of the output.
+			result = strings.NewReplacer("\n", "", "  ", "").Replace(result)
+		}
+		if result != test.expected {
+			t.Errorf("expected:\n%s\ngot:\n%s", test.expected, result)
+		}
+	}
+}
+
+func TestCmd_Schema_NestedTables(t *testing.T) {
+	// Arrange.
+	json := `[
+		{"id": 1, "orders": [{"n": 1, "shipments": [{"w": 2}]}, {"n": 2}]},
+		{"id": 2, "tags": ["a", "b"]}
+	]`
+
+	type TestCase struct {
+		json     bool
+		expected string
+	}
+	cases := []TestCase{
+		{
+			false,
+			`COLUMN  TYPES   PRESENT  NULL  EXAMPLES
+id      number  2/2      0     1, 2
+orders  array   1/2      0     [{"n": 1,"shipments": [{"w": ...
+tags    array   1/2      0     ["a","b"]
+
+TABLE orders (2 rows)
+COLUMN     TYPES   PRESENT  NULL  EXAMPLES
+n          number  2/2      0     1, 2
+shipments  array   1/2      0     [{"w": 2}]
+
+TABLE orders$shipments (1 row)
+COLUMN  TYPES   PRESENT  NULL  EXAMPLES
+w       number  1/1      0     2
+
+TABLE tags (2 rows)`,
+		},
+		{
+			true,
+			`{"rows": 2,"columns": [` +
+				`{"name": "id","types": {"number": 2},"present": 2,"null": 0,"examples": [1,2]},` +
+				`{"name": "orders","types": {"array": 1},"present": 1,"null": 0,"examples": [[{"n": 1,"shipments": [{"w": 2}]},{"n": 2}]]},` +
+				`{"name": "tags","types": {"array": 1},"present": 1,"null": 0,"examples": [["a","b"]]}],` +
+				`"tables": [` +
+				`{"name": "orders","rows": 2,"columns": [` +
+				`{"name": "n","types": {"number": 2},"present": 2,"null": 0,"examples": [1,2]},` +
+				`{"name": "shipments","types": {"array": 1},"present": 1,"null": 0,"examples": [[{"w": 2}]]}]},` +
+				`{"name": "orders$shipments","rows": 1,"columns": [` +
+				`{"name": "w","types": {"number": 1},"present": 1,"null": 0,"examples": [2]}]},` +
+				`{"name": "tags","rows": 2,"columns": []}]}`,
+		},
+	}
+
+	for _, test := range cases {
+		ioIn = bytes.NewReader([]byte(json))
+		ioOut = bytes.NewBuffer(nil)
+		ioErr = bytes.NewBuffer(nil)
+
+		// Act.
+		vars := schemaCmdVars{
+			inputFiles: nil,
+			json:       test.json,
+		}
+		err := runSchemaCmd(&vars)
+
+		// Assert.
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		result := strings.Trim(ioOut.(*bytes.Buffer).String(), "\n")
+		if test.json {
+			// Compare against the compact form of the output.
+			result = strings.NewReplacer("\n", "", "  ", "").Replace(result)
+		}
+		if result != test.expected {
+			t.Errorf("expected:\n%s\ngot:\n%s", test.expected, result)
+		}
+	}
+}
+
+func TestCmd_Flags(t *testing.T) {
+	// Arrange.
+	vars := rootCmdVars{}
+	rootCmd := newRootCmd(&vars)
+
+	// Act.
+	err := rootCmd.ParseFlags([]string{"-c", "--nth", "-2", "--lines", "--values"})
+
+	// Assert.
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !vars.compact || vars.nth != "-2" || !vars.lines || !vars.values {
+		t.Errorf("unexpected flag values: %+v", vars)
+	}
+}
+
+func TestCmd_Flags_Unknown(t *testing.T) {
+	// Arrange.
+	vars := rootCmdVars{}
+	rootCmd := newRootCmd(&vars)
+	rootCmd.SetArgs([]string{"--bogus", "SELECT 1"})
+	rootCmd.SetOut(ioutil.Discard)
+	rootCmd.SetErr(ioutil.Discard)
+
+	// Act.
+	err := rootCmd.Execute()
+
+	// Assert.
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if vars.query != "" {
+		t.Errorf("unexpected query: %q", vars.query)
+	}
+}
+
+func TestCmd_Nth(t *testing.T) {
+	// Arrange.
+	json := `[{"id": 0}, {"id": 1}, {"id": 2}, {"id": 3}]`
+
+	type TestCase struct {
+		nth      string
+		expected []string
+	}
+	cases := []TestCase{
+		{"0", []string{"0"}},
+		{"2", []string{"2"}},
+		{"3", []string{"3"}},
+		{"-1", []string{"3"}},
+		{"-4", []string{"0"}},
+		{"4", []string{""}},
+		{"-5", []string{""}},
+	}
+
+	for i, test := range cases {
+		vtable.Driver = fmt.Sprintf("TestCmd_Nth_%d", i)
+		ioIn = bytes.NewReader([]byte(json))
+		ioOut = bytes.NewBuffer(nil)
+		ioErr = bytes.NewBuffer(nil)
+
+		// Act.
+		vars := rootCmdVars{
+			query:      "SELECT id FROM []",
+			inputFiles: nil,
+			nth:        test.nth,
+			compact:    false,
+			values:     true,
+		}
+		err := runRootCmd(&vars, nil, nil)
+
+		// Assert.
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		result := ioOut.(*bytes.Buffer).String()
+		result = strings.Trim(result, "\n")
+
+		splitResult := strings.Split(result, "\n")
+		if len(splitResult) != len(test.expected) {
+			t.Fatalf("unexpected number of values")
+		}
+
+		for j, value := range splitResult {
+			if value != test.expected[j] {
+				t.Errorf("--nth %s: expected %s, got %s", test.nth, test.expected[j], value)
+			}
+		}
+	}
+}
+
+func TestCmd_Nth_MissingNestedTable(t *testing.T) {
+	// Arrange.
+	vtable.Driver = "TestCmd_Nth_MissingNestedTable"
+	ioIn = bytes.NewReader([]byte(`{"items": [{"sku": "a"}]} {"id": 2}`))
+	ioOut = bytes.NewBuffer(nil)
+	ioErr = bytes.NewBuffer(nil)
+
+	// Act.
+	vars := rootCmdVars{
+		query:      "SELECT sku FROM items",
+		inputFiles: nil,
+		nth:        "-1",
+		compact:    true,
+	}
+	err := runRootCmd(&vars, nil, nil)
+
+	// Assert.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result := ioOut.(*bytes.Buffer).String(); result != "" {
+		t.Errorf("unexpected output: %q", result)
+	}
+}
+
+func TestCmd_Nth_Invalid(t *testing.T) {
+	// Arrange.
+	ioIn = bytes.NewReader([]byte(`{"id": 0}`))
+	ioOut = bytes.NewBuffer(nil)
+	ioErr = bytes.NewBuffer(nil)
+
+	// Act.
+	vars := rootCmdVars{
+		query: "SELECT id FROM []",
+		nth:   "first",
+	}
+	err := runRootCmd(&vars, nil, nil)
+
+	// Assert.
+	if err == nil || err.Error() != `invalid --nth value "first", expected an integer` {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// writeInputs writes each of files to a temporary directory, returning the
+// directory.
+func writeInputs(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "sqj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestCmd_MultipleInputs(t *testing.T) {
+	// Arrange.
+	dir := writeInputs(t, map[string]string{
+		"orders.json":     `[{"id": 1, "customer": 2, "total": 9.5}, {"id": 2, "customer": 1, "total": 3}]`,
+		"customers.jsonl": "{\"id\": 1, \"name\": \"velit\"}\n{\"id\": 2, \"name\": \"culpa\"}\n",
+	})
+	defer os.RemoveAll(dir)
+	orders := filepath.Join(dir, "orders.json")
+	customers := filepath.Join(dir, "customers.jsonl")
+
+	type TestCase struct {
+		statement string
+		files     []string
+		expected  []string
+	}
+	cases := []TestCase{
+		{
+			"SELECT o.id, c.name FROM [] AS o JOIN customers AS c ON o.customer = c.id",
+			[]string{orders, customers},
+			[]string{`{"id": 1,"name": "culpa"}`, `{"id": 2,"name": "velit"}`},
+		},
+		{
+			"SELECT o.id, c.name FROM o JOIN c ON o.customer = c.id WHERE o.total > 5",
+			[]string{"o=" + orders, "c=" + customers},
+			[]string{`{"id": 1,"name": "culpa"}`},
+		},
+		{
+			"SELECT name, (SELECT COUNT(*) FROM orders WHERE customer = customers.id) AS n FROM customers",
+			[]string{"customers=" + customers, orders},
+			[]string{`{"name": "velit","n": 1}`, `{"name": "culpa","n": 1}`},
+		},
+		{
+			"SELECT COUNT(*) AS n FROM [], orders",
+			[]string{"-", orders},
+			[]string{`{"n": 2}`},
+		},
+		{
+			"SELECT c.name FROM Customers AS c ORDER BY c.id",
+			[]string{orders, customers},
+			[]string{`{"name": "velit"}`, `{"name": "culpa"}`},
+		},
+	}
+
+	for i, test := range cases {
+		vtable.Driver = fmt.Sprintf("TestCmd_MultipleInputs_%d", i)
+		ioIn = bytes.NewReader([]byte(`{"id": 0}`))
+		ioOut = bytes.NewBuffer(nil)
+		ioErr = bytes.NewBuffer(nil)
+
+		// Act.
+		vars := rootCmdVars{
+			query:      test.statement,
+			inputFiles: test.files,
+			nth:        "",
+			compact:    true,
+		}
+		if err := runRootCmd(&vars, nil, nil); err != nil {
+			t.Fatalf("unexpected error for %q: %v", test.statement, err)
+		}
+
+		// Assert.
+		result := strings.Trim(ioOut.(*bytes.Buffer).String(), "\n")
+		splitResult := strings.Split(result, "\n")
+		if len(splitResult) != len(test.expected) {
+			t.Fatalf("unexpected number of rows for %q: %q", test.statement, result)
+		}
+
+		for j, value := range splitResult {
+			if value != test.expected[j] {
+				t.Errorf("expected %s, got %s", test.expected[j], value)
+			}
+		}
+	}
+}
+
+func TestCmd_MultipleInputs_Invalid(t *testing.T) {
+	type TestCase struct {
+		files    []string
+		expected string
+	}
+	cases := []TestCase{
+		{[]string{"a.json", "2020-01.json"}, `cannot name a table after "2020-01.json", use NAME=2020-01.json`},
+		{[]string{"a.json", "-"}, `cannot name a table after "-", use NAME=-`},
+		{[]string{"-", "b=-"}, "stdin can only be read once"},
+		{[]string{"a.json", "x/b.json", "y/B.json"}, `duplicate table name "B"`},
+	}
+
+	for _, _case := range cases {
+		// Act.
+		vars := rootCmdVars{
+			query:      "SELECT 1",
+			inputFiles: _case.files,
+		}
+		err := runRootCmd(&vars, nil, nil)
+
+		// Assert.
+		if err == nil || err.Error() != _case.expected {
+			t.Errorf("expected %q for %v, got %v", _case.expected, _case.files, err)
+		}
+	}
+}
+
+func TestCmd_MultipleInputs_UnknownTable(t *testing.T) {
+	// Arrange.
+	vtable.Driver = "TestCmd_MultipleInputs_UnknownTable"
+	dir := writeInputs(t, map[string]string{"customers.json": `{"id": 1}`})
+	defer os.RemoveAll(dir)
+	ioIn = bytes.NewReader([]byte(`{"id": 1}`))
+	ioOut = bytes.NewBuffer(nil)
+	ioErr = bytes.NewBuffer(nil)
+
+	// Act.
+	vars := rootCmdVars{
+		query:      "SELECT c.id FROM customerz AS c",
+		inputFiles: []string{"-", filepath.Join(dir, "customers.json")},
+	}
+	err := runRootCmd(&vars, nil, nil)
+
+	// Assert.
+	if expected := "no such table: customerz"; err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+	if result := ioOut.(*bytes.Buffer).String(); result != "" {
+		t.Errorf("unexpected output: %q", result)
+	}
+}
+
+func TestCmd_UnknownTable_NoPartialOutput(t *testing.T) {
+	// Arrange.
+	vtable.Driver = "TestCmd_UnknownTable_NoPartialOutput"
+	ioIn = bytes.NewReader([]byte(`[{"id": 1, "items": []}, {"id": 2}]`))
+	ioOut = bytes.NewBuffer(nil)
+	ioErr = bytes.NewBuffer(nil)
+
+	// Act.
+	vars := rootCmdVars{
+		query:      "SELECT o.id, i.sku FROM [] AS o LEFT JOIN itmes AS i ON i.sku = o.id",
+		inputFiles: nil,
+		compact:    true,
+	}
+	err := runRootCmd(&vars, nil, nil)
+
+	// Assert.
+	if expected := "no such table: itmes"; err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+	if result := ioOut.(*bytes.Buffer).String(); result != "" {
+		t.Errorf("unexpected output: %q", result)
+	}
+}
+
+func TestCmd_MultipleInputs_MalformedInput(t *testing.T) {
+	// Arrange.
+	vtable.Driver = "TestCmd_MultipleInputs_MalformedInput"
+	dir := writeInputs(t, map[string]string{"b.json": `{"id": 1}`, "c.json": `{"id" 2}`})
+	defer os.RemoveAll(dir)
+	ioIn = bytes.NewReader([]byte(`{"id": 1}`))
+	ioOut = bytes.NewBuffer(nil)
+	ioErr = bytes.NewBuffer(nil)
+
+	// Act.
+	vars := rootCmdVars{
+		query:      "SELECT b.id FROM b JOIN c ON b.id = c.id",
+		inputFiles: []string{"-", filepath.Join(dir, "b.json"), filepath.Join(dir, "c.json")},
+	}
+	err := runRootCmd(&vars, nil, nil)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	printError(ioErr, &vars, err)
+
+	// Assert.
+	expected := "sqj: " + filepath.Join(dir, "c.json") + ":1:7: expected ':', got 2\n" +
+		"    {\"id\" 2}\n" +
+		"          ^\n"
+	if result := ioErr.(*bytes.Buffer).String(); result != expected {
+		t.Errorf("unexpected diagnostic: %q", result)
+	}
+}
+
+func TestCmd_DirectoryInputs(t *testing.T) {
+	// Arrange.
+	dir := writeInputs(t, map[string]string{
+		"a.json":  `{"id": 1}`,
+		"b.jsonl": "{\"id\": 2}\n{\"id\": 3}\n",
+		"c.txt":   "not json",
+	})
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "sub", "d.json"), []byte(`[{"id": 4}, {"id": 5}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	a, b, d := filepath.Join(dir, "a.json"), filepath.Join(dir, "b.jsonl"), filepath.Join(dir, "sub", "d.json")
+
+	type TestCase struct {
+		statement string
+		files     []string
+		recursive bool
+		expected  []string
+	}
+	cases := []TestCase{
+		{
+			"SELECT id, _file FROM []",
+			[]string{dir},
+			false,
+			[]string{
+				fmt.Sprintf(`{"id": 1,"_file": %q}`, a),
+				fmt.Sprintf(`{"id": 2,"_file": %q}`, b),
+				fmt.Sprintf(`{"id": 3,"_file": %q}`, b),
+			},
+		},
+		{
+			"SELECT _file, COUNT(*) AS n FROM [] GROUP BY _file",
+			[]string{dir},
+			true,
+			[]string{
+				fmt.Sprintf(`{"_file": %q,"n": 1}`, a),
+				fmt.Sprintf(`{"_file": %q,"n": 2}`, b),
+				fmt.Sprintf(`{"_file": %q,"n": 2}`, d),
+			},
+		},
+		{
+			"SELECT id FROM [] WHERE _file LIKE '%.json' ORDER BY id",
+			[]string{filepath.Join(dir, "*", "*.json")},
+			false,
+			[]string{`{"id": 4}`, `{"id": 5}`},
+		},
+		{
+			"SELECT r.id FROM [] AS r JOIN ids ON r.id = ids.id + 3",
+			[]string{filepath.Join(dir, "sub", "*"), "ids=" + dir},
+			false,
+			[]string{`{"id": 4}`, `{"id": 5}`},
+		},
+		{
+			"SELECT COUNT(*) AS n, MAX(_file) = '-' AS stdin FROM []",
+			[]string{},
+			false,
+			[]string{`{"n": 1,"stdin": 1}`},
+		},
+	}
+
+	for i, test := range cases {
+		vtable.Driver = fmt.Sprintf("TestCmd_DirectoryInputs_%d", i)
+		ioIn = bytes.NewReader([]byte(`{"id": 0}`))
+		ioOut = bytes.NewBuffer(nil)
+		ioErr = bytes.NewBuffer(nil)
+
+		// Act.
+		vars := rootCmdVars{
+			query:      test.statement,
+			inputFiles: test.files,
+			nth:        "",
+			compact:    true,
+			recursive:  test.recursive,
+		}
+		if err := runRootCmd(&vars, nil, nil); err != nil {
+			t.Fatalf("unexpected error for %q: %v", test.statement, err)
+		}
+
+		// Assert.
+		result := strings.Trim(ioOut.(*bytes.Buffer).String(), "\n")
+		splitResult := strings.Split(result, "\n")
+		if result == "" {
+			splitResult = nil
+		}
+		if len(splitResult) != len(test.expected) {
+			t.Fatalf("unexpected number of rows for %q: %q", test.statement, result)
+		}
+
+		for j, value := range splitResult {
+			if value != test.expected[j] {
+				t.Errorf("expected %s, got %s", test.expected[j], value)
+			}
+		}
+	}
+}
+
+func TestCmd_DirectoryInputs_Errors(t *testing.T) {
+	// Arrange.
+	dir := writeInputs(t, map[string]string{"a.json": `{"id": 1}`, "b.json": "{\"id\": 2}\n{\"id\" 3}"})
+	defer os.RemoveAll(dir)
+
+	type TestCase struct {
+		files    []string
+		expected string
+	}
+	cases := []TestCase{
+		{
+			[]string{filepath.Join(dir, "*.jsonl")},
+			fmt.Sprintf("sqj: no files match %q\n", filepath.Join(dir, "*.jsonl")),
+		},
+		{
+			[]string{dir},
+			"sqj: " + filepath.Join(dir, "b.json") + ":2:7: expected ':', got 3\n" +
+				"    {\"id\" 3}\n" +
+				"          ^\n",
+		},
+	}
+
+	for i, _case := range cases {
+		vtable.Driver = fmt.Sprintf("TestCmd_DirectoryInputs_Errors_%d", i)
+		ioOut = bytes.NewBuffer(nil)
+		ioErr = bytes.NewBuffer(nil)
+
+		// Act.
+		vars := rootCmdVars{
+			query:      "SELECT id FROM []",
+			inputFiles: _case.files,
+		}
+		err := runRootCmd(&vars, nil, nil)
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		printError(ioErr, &vars, err)
+
+		// Assert.
+		if result := ioErr.(*bytes.Buffer).String(); result != _case.expected {
+			t.Errorf("unexpected diagnostic: %q", result)
+		}
+	}
+}
+
+func TestCmd_StdIn_MalformedInput(t *testing.T) {
+	// Arrange.
+	vtable.Driver = "TestCmd_StdIn_MalformedInput"
+	json := "[\n\t{\"id\": 1},\n\t{\"id\" 2}\n]"
+	ioIn = bytes.NewReader([]byte(json))
+	ioOut = bytes.NewBuffer(nil)
+	ioErr = bytes.NewBuffer(nil)
+
+	// Act.
+	vars := rootCmdVars{
+		query:      "SELECT id FROM []",
+		inputFiles: nil,
+		nth:        "",
+		compact:    false,
+	}
+	err := runRootCmd(&vars, nil, nil)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	printError(ioErr, &vars, err)
+
+	// Assert.
+	expected := "sqj: <stdin>:3:8: expected ':', got 2\n" +
+		"    \t{\"id\" 2}\n" +
+		"    \t      ^\n"
+	if result := ioErr.(*bytes.Buffer).String(); result != expected {
+		t.Errorf("unexpected diagnostic: %q", result)
+	}
+}
+
+func TestCmd_StdIn_MalformedInput_CRLF(t *testing.T) {
+	// Arrange.
+	vtable.Driver = "TestCmd_StdIn_MalformedInput_CRLF"
+	ioIn = bytes.NewReader([]byte("{\"a\":1,\r"))
+	ioOut = bytes.NewBuffer(nil)
+	ioErr = bytes.NewBuffer(nil)
+
+	// Act.
+	vars := rootCmdVars{
+		query:      "SELECT a FROM []",
+		inputFiles: nil,
+		nth:        "",
+		compact:    false,
+	}
+	err := runRootCmd(&vars, nil, nil)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	printError(ioErr, &vars, err)
+
+	// Assert.
+	expected := "sqj: <stdin>:1:9: expected a member name, got end of input\n" +
+		"    {\"a\":1,\n" +
+		"           ^\n"
+	if result := ioErr.(*bytes.Buffer).String(); result != expected {
+		t.Errorf("unexpected diagnostic: %q", result)
+	}
+}
+
+func TestCmd_MalformedQuery(t *testing.T) {
+	// Arrange.
+	ioIn = bytes.NewReader([]byte(`{"id": 1}`))
+	ioOut = bytes.NewBuffer(nil)
+	ioErr = bytes.NewBuffer(nil)
+
+	// Act.
+	vars := rootCmdVars{
+		query:      "SELECT id\nFROM [] WHERE id >",
+		inputFiles: nil,
+		nth:        "",
+		compact:    false,
+	}
+	err := runRootCmd(&vars, nil, nil)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	printError(ioErr, &vars, err)
+
+	// Assert.
+	expected := "sqj: query:2:19: expected an expression, got end of input\n" +
+		"    FROM [] WHERE id >\n" +
+		"                      ^\n"
+	if result := ioErr.(*bytes.Buffer).String(); result != expected {
+		t.Errorf("unexpected diagnostic: %q", result)
+	}
+}
+
+func TestCmd_Arrows(t *testing.T) {
+	// Arrange.
+	json := `[
+		{"id": 1, "content": [{"id": 0, "word": "velit", "ok": true}, {"id": 1, "word": "culpa"}], "about": {"a b": [1, 2]}},
+		{"id": 2, "content": [], "about": null}
+	]`
+
+	type TestCase struct {
+		statement string
+		expected  []string
+	}
+	cases := []TestCase{
+		{
+			"SELECT content->>'$[0].word' FROM []",
+			[]string{`{"content->>'$[0].word'": "velit"}`, `{"content->>'$[0].word'": null}`},
+		},
+		{
+			"SELECT content->'$[0]' AS c, content->'$[#-1].word' AS w FROM [] WHERE id = 1",
+			[]string{`{"c": {"id": 0,"word": "velit","ok": true},"w": "culpa"}`},
+		},
+		{
+			"SELECT content->>0->>'ok' AS ok FROM [] WHERE id = 1",
+			[]string{`{"ok": true}`},
+		},
+		{
+			"SELECT about->'a b'->>-1 AS n, about->>'$.\"a b\"[0]' + 1 AS m FROM [] WHERE id = 1",
+			[]string{`{"n": 2,"m": 2}`},
+		},
+		{
+			"SELECT id FROM [] WHERE content->>'$[1].word' = 'culpa' AND content->'$[0].word' = '\"velit\"'",
+			[]string{`{"id": 1}`},
+		},
+		{
+			"SELECT p.id, length(p.content->'$') AS n FROM [] AS p ORDER BY p.content->>'$[0].id' DESC, p.id",
+			[]string{`{"id": 1,"n": 64}`, `{"id": 2,"n": 2}`},
+		},
+	}
+
+	for i, test := range cases {
+		vtable.Driver = fmt.Sprintf("TestCmd_Arrows_%d", i)
+		ioIn = bytes.NewReader([]byte(json))
+		ioOut = bytes.NewBuffer(nil)
+		ioErr = bytes.NewBuffer(nil)
+
+		// Act.
+		vars := rootCmdVars{
+			query:      test.statement,
+			inputFiles: nil,
+			compact:    true,
+		}
+		if err := runRootCmd(&vars, nil, nil); err != nil {
+			t.Fatalf("unexpected error for %q: %v", test.statement, err)
+		}
+
+		// Assert.
+		result := strings.Trim(ioOut.(*bytes.Buffer).String(), "\n")
+		splitResult := strings.Split(result, "\n")
+		if len(splitResult) != len(test.expected) {
+			t.Fatalf("unexpected number of rows for %q: %q", test.statement, result)
+		}
+
+		for j, value := range splitResult {
+			if value != test.expected[j] {
+				t.Errorf("expected %s, got %s", test.expected[j], value)
+			}
+		}
+	}
+}
+
+func TestCmd_Compound(t *testing.T) {
+	// Arrange.
+	json := `[
+		{"id": 1, "tags": ["a", "b"], "labels": ["b", "c"]},
+		{"id": 2, "tags": ["c"], "labels": []}
+	]`
+
+	type TestCase struct {
+		statement string
+		expected  []string
+	}
+	cases := []TestCase{
+		{
+			"SELECT id FROM [] UNION ALL SELECT id + 10 FROM [] ORDER BY id DESC LIMIT 3",
+			[]string{`{"id": 12}`, `{"id": 11}`, `{"id": 2}`},
+		},
+		{
+			"SELECT t.value AS v FROM [] AS o, each(o.tags) AS t UNION SELECT l.value FROM [] AS o, each(o.labels) AS l ORDER BY v",
+			[]string{`{"v": "a"}`, `{"v": "b"}`, `{"v": "c"}`},
+		},
+		{
+			"SELECT t.value AS v FROM [] AS o, each(o.tags) AS t INTERSECT SELECT l.value FROM [] AS o, each(o.labels) AS l",
+			[]string{`{"v": "b"}`, `{"v": "c"}`},
+		},
+		{
+			"SELECT t.value AS v FROM [] AS o, each(o.tags) AS t EXCEPT SELECT l.value FROM [] AS o, each(o.labels) AS l",
+			[]string{`{"v": "a"}`},
+		},
+		{
+			"SELECT a.id, b.id AS other FROM [] AS a CROSS JOIN [] AS b WHERE a.id < b.id",
+			[]string{`{"id": 1,"other": 2}`},
+		},
+	}
+
+	for i, test := range cases {
+		vtable.Driver = fmt.Sprintf("TestCmd_Compound_%d", i)
+		ioIn = bytes.NewReader([]byte(json))
+		ioOut = bytes.NewBuffer(nil)
+		ioErr = bytes.NewBuffer(nil)
+
+		// Act.
+		vars := rootCmdVars{
+			query:      test.statement,
+			inputFiles: nil,
+			compact:    true,
+		}
+		if err := runRootCmd(&vars, nil, nil); err != nil {
+			t.Fatalf("unexpected error for %q: %v", test.statement, err)
+		}
+
+		// Assert.
+		result := strings.Trim(ioOut.(*bytes.Buffer).String(), "\n")
+		splitResult := strings.Split(result, "\n")
+		if len(splitResult) != len(test.expected) {
+			t.Fatalf("unexpected number of rows for %q: %q", test.statement, result)
+		}
+
+		for j, value := range splitResult {
+			if value != test.expected[j] {
+				t.Errorf("expected %s, got %s", test.expected[j], value)
+			}
+		}
+	}
+}
+
+func TestCmd_CommonTables(t *testing.T) {
+	// Arrange.
+	json := `[
+		{"id": 1, "name": "Ada", "manager": null},
+		{"id": 2, "name": "Bob", "manager": 1},
+		{"id": 3, "name": "Cy", "manager": 2},
+		{"id": 4, "name": "Di", "manager": 1}
+	]`
+
+	type TestCase struct {
+		statement string
+		expected  []string
+	}
+	cases := []TestCase{
+		{
+			"WITH reports AS (SELECT manager, COUNT(*) AS n FROM [] GROUP BY manager) " +
+				"SELECT e.name, r.n FROM [] AS e JOIN reports AS r ON r.manager = e.id ORDER BY e.id",
+			[]string{`{"name": "Ada","n": 2}`, `{"name": "Bob","n": 1}`},
+		},
+		{
+			"WITH RECURSIVE chain(id, name, depth) AS (" +
+				"SELECT id, name, 0 FROM [] WHERE id = 1 " +
+				"UNION ALL SELECT e.id, e.name, c.depth + 1 FROM [] AS e JOIN chain AS c ON e.manager = c.id" +
+				") SELECT name, depth FROM chain ORDER BY depth, name",
+			[]string{
+				`{"name": "Ada","depth": 0}`,
+				`{"name": "Bob","depth": 1}`,
+				`{"name": "Di","depth": 1}`,
+				`{"name": "Cy","depth": 2}`,
+			},
+		},
+		{
+			"WITH RECURSIVE n(x) AS (SELECT 1 UNION ALL SELECT n.x + 1 FROM n WHERE n.x < 2) " +
+				"SELECT n.x, p.name FROM n, [] AS p WHERE p.id = n.x",
+			[]string{`{"x": 1,"name": "Ada"}`, `{"x": 2,"name": "Bob"}`},
+		},
+	}
+
+	for i, test := range cases {
+		vtable.Driver = fmt.Sprintf("TestCmd_CommonTables_%d", i)
+		ioIn = bytes.NewReader([]byte(json))
+		ioOut = bytes.NewBuffer(nil)
+		ioErr = bytes.NewBuffer(nil)
+
+		// Act.
+		vars := rootCmdVars{
+			query:      test.statement,
+			inputFiles: nil,
+			compact:    true,
+		}
+		if err := runRootCmd(&vars, nil, nil); err != nil {
+			t.Fatalf("unexpected error for %q: %v", test.statement, err)
+		}
+
+		// Assert.
+		result := strings.Trim(ioOut.(*bytes.Buffer).String(), "\n")
+		splitResult := strings.Split(result, "\n")
+		if len(splitResult) != len(test.expected) {
+			t.Fatalf("unexpected number of rows for %q: %q", test.statement, result)
+		}
+
+		for j, value := range splitResult {
+			if value != test.expected[j] {
+				t.Errorf("expected %s, got %s", test.expected[j], value)
+			}
+		}
+	}
+}
+
+func TestCmd_WindowFunctions(t *testing.T) {
+	// Arrange.
+	json := `[
+		{"user": "ada", "ts": 1, "amount": 5},
+		{"user": "bob", "ts": 2, "amount": 3},
+		{"user": "ada", "ts": 3, "amount": 2},
+		{"user": "bob", "ts": 4, "amount": 7}
+	]`
+
+	type TestCase struct {
+		statement string
+		expected  []string
+	}
+	cases := []TestCase{
+		{
+			"SELECT user, ts, ROW_NUMBER() OVER (PARTITION BY user ORDER BY ts) AS n FROM [] ORDER BY ts",
+			[]string{
+				`{"user": "ada","ts": 1,"n": 1}`,
+				`{"user": "bob","ts": 2,"n": 1}`,
+				`{"user": "ada","ts": 3,"n": 2}`,
+				`{"user": "bob","ts": 4,"n": 2}`,
+			},
+		},
+		{
+			"SELECT ts, SUM(amount) OVER w AS total FROM [] " +
+				"WINDOW w AS (ORDER BY ts ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW) ORDER BY ts",
+			[]string{
+				`{"ts": 1,"total": 5}`,
+				`{"ts": 2,"total": 8}`,
+				`{"ts": 3,"total": 10}`,
+				`{"ts": 4,"total": 17}`,
+			},
+		},
+		{
+			"SELECT COUNT(*) FILTER (WHERE amount > 2) AS large, COUNT(*) AS n FROM []",
+			[]string{`{"large": 3,"n": 4}`},
+		},
+	}
+
+	for i, test := range cases {
+		vtable.Driver = fmt.Sprintf("TestCmd_WindowFunctions_%d", i)
+		ioIn = bytes.NewReader([]byte(json))
+		ioOut = bytes.NewBuffer(nil)
+		ioErr = bytes.NewBuffer(nil)
+
+		// Act.
+		vars := rootCmdVars{
+			query:      test.statement,
+			inputFiles: nil,
+			compact:    true,
+		}
+		if err := runRootCmd(&vars, nil, nil); err != nil {
+			t.Fatalf("unexpected error for %q: %v", test.statement, err)
+		}
+
+		// Assert.
+		result := strings.Trim(ioOut.(*bytes.Buffer).String(), "\n")
+		splitResult := strings.Split(result, "\n")
+		if len(splitResult) != len(test.expected) {
+			t.Fatalf("unexpected number of rows for %q: %q", test.statement, result)
+		}
+
+		for j, value := range splitResult {
+			if value != test.expected[j] {
+				t.Errorf("expected %s, got %s", test.expected[j], value)
+			}
+		}
+	}
+}
+
+func TestCmd_In(t *testing.T) {
+	// Arrange.
+	json := `[
+		{"id": 1, "tag": "a", "parent": null},
+		{"id": 2, "tag": "b", "parent": 1},
+		{"id": 3, "tag": "c", "parent": 1},
+		{"id": 4, "tag": "a", "parent": 3}
+	]`
+
+	type TestCase struct {
+		statement string
+		expected  []string
+	}
+	cases := []TestCase{
+		{
+			"SELECT id FROM [] WHERE id IN (1, 3, 5)",
+			[]string{`{"id": 1}`, `{"id": 3}`},
+		},
+		{
+			"SELECT id, tag IN ('a', 'c') AS ac FROM [] WHERE tag NOT IN ('b')",
+			[]string{`{"id": 1,"ac": 1}`, `{"id": 3,"ac": 1}`, `{"id": 4,"ac": 1}`},
+		},
+		{
+			"SELECT p.id FROM [] AS p WHERE p.id IN (SELECT c.parent FROM [] AS c WHERE c.tag = 'a')",
+			[]string{`{"id": 3}`},
+		},
+		{
+			"WITH leaves AS (SELECT id FROM [] WHERE id > 3) SELECT p.id FROM [] AS p WHERE p.id NOT IN leaves",
+			[]string{`{"id": 1}`, `{"id": 2}`, `{"id": 3}`},
+		},
+	}
+
+	for i, test := range cases {
+		vtable.Driver = fmt.Sprintf("TestCmd_In_%d", i)
+		ioIn = bytes.NewReader([]byte(json))
+		ioOut = bytes.NewBuffer(nil)
+		ioErr = bytes.NewBuffer(nil)
+
+		// Act.
+		vars := rootCmdVars{
+			query:      test.statement,
+			inputFiles: nil,
+			compact:    true,
+		}
+		if err := runRootCmd(&vars, nil, nil); err != nil {
+			t.Fatalf("unexpected error for %q: %v", test.statement, err)
+		}
+
+		// Assert.
+		result := strings.Trim(ioOut.(*bytes.Buffer).String(), "\n")
+		splitResult := strings.Split(result, "\n")
+		if len(splitResult) != len(test.expected) {
+			t.Fatalf("unexpected number of rows for %q: %q", test.statement, result)
+		}
+
+		for j, value := range splitResult {
+			if value != test.expected[j] {
+				t.Errorf("expected %s, got %s", test.expected[j], value)
+			}
+		}
+	}
+}
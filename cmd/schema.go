@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/progbits/sqjson/internal/json"
+	"github.com/spf13/cobra"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+type schemaCmdVars struct {
+	inputFiles []string
+	lines      bool
+	recursive  bool
+	json       bool
+}
+
+// Types in the order they are listed in a schema.
+var schemaTypes = []string{"object", "array", "number", "string", "boolean", "null"}
+
+// Maximum length of an example value in the table output.
+const maxExampleLength = 32
+
+// newSchemaCmd creates the schema command, storing its flags and arguments in
+// vars.
+func newSchemaCmd(vars *schemaCmdVars) *cobra.Command {
+	schemaCmd := &cobra.Command{
+		Use:   "schema [FILE]",
+		Short: "Describe the columns of the tables of a JSON input",
+		Long: `Describe the columns of the top-level table of a JSON input, with the
+types of their values, how often they are present or null, and example values.
+Nested arrays follow as tables of their own, named by their $-joined path.`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			vars.inputFiles = args
+			if err := runSchemaCmd(vars); err != nil {
+				printError(ioErr, &rootCmdVars{inputFiles: vars.inputFiles}, err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	schemaCmd.Flags().BoolVarP(&vars.lines, "lines", "l", false,
+		"Read newline-delimited JSON, one record per line (default for .jsonl and .ndjson files)")
+	schemaCmd.Flags().BoolVarP(&vars.recursive, "recursive", "r", false,
+		"Include JSON files in subdirectories of a directory input")
+	schemaCmd.Flags().BoolVar(&vars.json, "json", false,
+		"Print the schema as JSON rather than a table")
+
+	return schemaCmd
+}
+
+func runSchemaCmd(vars *schemaCmdVars) error {
+	path := "-"
+	if len(vars.inputFiles) > 0 {
+		path = vars.inputFiles[0]
+	}
+	reader, err := newFileReader(path, vars.recursive, vars.lines)
+	if err != nil {
+		return err
+	}
+
+	var schema json.Schema
+	for {
+		record, err := reader.Next()
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			break
+		}
+		schema.AddNested(record)
+	}
+
+	if vars.json {
+		json.PrettyPrint(ioOut, schemaNode(&schema), false)
+		_, _ = fmt.Fprintf(ioOut, "\n")
+		return nil
+	}
+	printSchemaTable(&schema)
+	return nil
+}
+
+// printSchemaTable writes a schema to ioOut as a table with a row per column,
+// followed by the same for each nested table.
+func printSchemaTable(schema *json.Schema) {
+	printColumnTable(schema)
+	for _, table := range schema.Tables {
+		rows := "rows"
+		if table.Rows == 1 {
+			rows = "row"
+		}
+		_, _ = fmt.Fprintf(ioOut, "\nTABLE %s (%d %s)\n", table.Name, table.Rows, rows)
+		if len(table.Columns) > 0 {
+			printColumnTable(table)
+		}
+	}
+}
+
+// printColumnTable writes the columns of a single table to ioOut.
+func printColumnTable(schema *json.Schema) {
+	w := tabwriter.NewWriter(ioOut, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "COLUMN\tTYPES\tPRESENT\tNULL\tEXAMPLES\n")
+	for i, column := range schema.Columns {
+		stats := schema.Stats[i]
+
+		// Counts are only shown for columns with values of several types.
+		var types []string
+		for _, name := range schemaTypes {
+			if stats.Types[name] > 0 && name != "null" {
+				types = append(types, name)
+			}
+		}
+		if len(types) > 1 {
+			for j, name := range types {
+				types[j] = fmt.Sprintf("%s (%d)", name, stats.Types[name])
+			}
+		}
+		if len(types) == 0 {
+			types = append(types, "null")
+		}
+
+		var examples []string
+		for _, example := range stats.Examples {
+			buf := bytes.NewBuffer(nil)
+			json.PrettyPrint(buf, example, true)
+			text := []rune(buf.String())
+			if len(text) > maxExampleLength {
+				text = append(text[:maxExampleLength-3], []rune("...")...)
+			}
+			examples = append(examples, string(text))
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%d/%d\t%d\t%s\n", column, strings.Join(types, ", "),
+			stats.Present, schema.Rows, stats.Types["null"], strings.Join(examples, ", "))
+	}
+	_ = w.Flush()
+}
+
+// schemaNode converts a schema to an AST. Nested tables are listed under
+// "tables" when present.
+func schemaNode(schema *json.Schema) *json.ASTNode {
+	node := &json.ASTNode{
+		Value:   json.JSON_VALUE_OBJECT,
+		Members: []*json.ASTNode{numberNode("rows", schema.Rows), columnsNode(schema)},
+	}
+	if len(schema.Tables) == 0 {
+		return node
+	}
+
+	tables := &json.ASTNode{Value: json.JSON_VALUE_ARRAY, Name: "tables"}
+	for _, table := range schema.Tables {
+		tables.Values = append(tables.Values, &json.ASTNode{
+			Value: json.JSON_VALUE_OBJECT,
+			Members: []*json.ASTNode{
+				{Value: json.JSON_VALUE_STRING, Name: "name", String: table.Name},
+				numberNode("rows", table.Rows),
+				columnsNode(table),
+			},
+		})
+	}
+	node.Members = append(node.Members, tables)
+	return node
+}
+
+// columnsNode converts the columns of a single table to an AST.
+func columnsNode(schema *json.Schema) *json.ASTNode {
+	columns := &json.ASTNode{Value: json.JSON_VALUE_ARRAY, Name: "columns"}
+	for i, column := range schema.Columns {
+		stats := schema.Stats[i]
+
+		types := &json.ASTNode{Value: json.JSON_VALUE_OBJECT, Name: "types"}
+		for _, name := range schemaTypes {
+			if stats.Types[name] > 0 {
+				types.Members = append(types.Members, numberNode(name, stats.Types[name]))
+			}
+		}
+
+		examples := &json.ASTNode{Value: json.JSON_VALUE_ARRAY, Name: "examples"}
+		for _, example := range stats.Examples {
+			value := *example
+			value.Name = ""
+			examples.Values = append(examples.Values, &value)
+		}
+
+		columns.Values = append(columns.Values, &json.ASTNode{
+			Value: json.JSON_VALUE_OBJECT,
+			Members: []*json.ASTNode{
+				{Value: json.JSON_VALUE_STRING, Name: "name", String: column},
+				types,
+				numberNode("present", stats.Present),
+				numberNode("null", stats.Types["null"]),
+				examples,
+			},
+		})
+	}
+
+	return columns
+}
+
+// numberNode returns a named node holding an integer.
+func numberNode(name string, value int) *json.ASTNode {
+	return &json.ASTNode{
+		Value:   json.JSON_VALUE_NUMBER,
+		Name:    name,
+		Number:  float64(value),
+		Literal: strconv.Itoa(value),
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 // Allowed JSON values.
@@ -38,8 +40,47 @@ type ASTNode struct {
 	// Value for tokens of type NUMBER.
 	Number float64
 
+	// Original text of tokens of type NUMBER, used to print the number exactly
+	// as it appeared in the input. Empty for computed values.
+	Literal string
+
 	// Value for tokens of type STRING.
 	String string
+
+	// Position of object members by name, built on first lookup of a member
+	// of a large object, and the number of members it covers.
+	index   map[string]int
+	indexed int
+}
+
+// Objects with fewer members than this are searched linearly, as this is
+// quicker than building an index.
+const memberIndexThreshold = 16
+
+// member returns the position of the first member of an object with the given
+// name, or -1 if the object has no such member.
+func (n *ASTNode) member(name string) int {
+	if len(n.Members) < memberIndexThreshold {
+		for i := 0; i < len(n.Members); i++ {
+			if n.Members[i].Name == name {
+				return i
+			}
+		}
+		return -1
+	}
+
+	// Rebuild the index if members have been added since it was built.
+	if n.index == nil || n.indexed != len(n.Members) {
+		n.index = make(map[string]int, len(n.Members))
+		for i := len(n.Members) - 1; i >= 0; i-- {
+			n.index[n.Members[i].Name] = i
+		}
+		n.indexed = len(n.Members)
+	}
+	if i, ok := n.index[name]; ok {
+		return i
+	}
+	return -1
 }
 
 // Compare two ASTs for equality.
@@ -96,35 +137,190 @@ func equal(a, b *ASTNode) bool {
 }
 
 // Find an AST node by name.
+//
+// Names of nested members are the names of each of their parents and their
+// own name, separated by $. The name of the node itself, if any, is the first
+// part of the name.
 func FindNode(ast *ASTNode, name string) *ASTNode {
-	var result *ASTNode
-	findNodeImpl(ast, &result, name, "")
-	return result
+	if ast == nil {
+		return nil
+	}
+
+	if ast.Name == name {
+		return ast
+	}
+	if ast.Name != "" {
+		if !strings.HasPrefix(name, ast.Name+"$") {
+			return nil
+		}
+		name = name[len(ast.Name)+1:]
+	}
+	return findMember(ast, name)
 }
 
-func findNodeImpl(ast *ASTNode, result **ASTNode, target, prefix string) bool {
+// FindMember finds a nested member of an object by name, as FindNode, but
+// regardless of the name of the object itself.
+func FindMember(ast *ASTNode, name string) *ASTNode {
 	if ast == nil {
-		return false
+		return nil
 	}
+	return findMember(ast, name)
+}
 
-	columnName := concatPrefixName(prefix, ast.Name)
-	if columnName == target {
-		*result = ast
-		return true
+// Match is a node found by FindAll.
+type Match struct {
+	Node *ASTNode
+
+	// JSON pointer of the node relative to the node searched.
+	//
+	// RFC 6901.
+	Pointer string
+}
+
+// FindAll finds the AST nodes with a name, as FindNode, but also descending
+// into each element of the arrays met along the way. Elements not containing
+// the rest of the name are skipped. Nodes are returned in document order.
+func FindAll(ast *ASTNode, name string) []Match {
+	if ast == nil {
+		return nil
 	}
 
-	if ast.Value == JSON_VALUE_OBJECT {
-		for i := 0; i < len(ast.Members); i++ {
-			found := findNodeImpl(ast.Members[i], result, target, columnName)
-			if found {
-				return true
+	if ast.Name == name {
+		return []Match{{Node: ast}}
+	}
+	if ast.Name != "" {
+		if !strings.HasPrefix(name, ast.Name+"$") {
+			return nil
+		}
+		name = name[len(ast.Name)+1:]
+	}
+
+	matches := make([]Match, 0)
+	findAll(ast, name, "", &matches)
+	return matches
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// findAll appends the nodes with a name nested within ast to matches. As with
+// findMember, the earliest member of an object with a match is used where
+// several members match parts of the name.
+func findAll(ast *ASTNode, name, pointer string, matches *[]Match) {
+	switch ast.Value {
+	case JSON_VALUE_ARRAY:
+		for i, value := range ast.Values {
+			findAll(value, name, pointer+"/"+strconv.Itoa(i), matches)
+		}
+		return
+	case JSON_VALUE_OBJECT:
+	default:
+		return
+	}
+
+	// Find the members matching the whole name, or a part of it, in member
+	// order.
+	type candidate struct {
+		member int
+		rest   int // Start of the rest of the name, or -1 for a whole match.
+	}
+	candidates := make([]candidate, 0, 1)
+	if i := ast.member(name); i >= 0 {
+		candidates = append(candidates, candidate{i, -1})
+	}
+	for j := 0; j < len(name); j++ {
+		if name[j] != '$' {
+			continue
+		}
+		if i := ast.member(name[:j]); i >= 0 {
+			candidates = append(candidates, candidate{i, j + 1})
+		}
+	}
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return candidates[a].member < candidates[b].member
+	})
+
+	for _, c := range candidates {
+		member := ast.Members[c.member]
+		memberPointer := pointer + "/" + pointerEscaper.Replace(member.Name)
+		if c.rest < 0 {
+			*matches = append(*matches, Match{Node: member, Pointer: memberPointer})
+			return
+		}
+
+		n := len(*matches)
+		findAll(member, name[c.rest:], memberPointer, matches)
+		if len(*matches) > n {
+			return
+		}
+	}
+}
+
+// findMember finds a nested member of an object by descending through its
+// members. Member names may themselves contain $, so several members may match
+// parts of the name, in which case the earliest member with a match is used.
+func findMember(ast *ASTNode, name string) *ASTNode {
+	if ast.Value != JSON_VALUE_OBJECT {
+		return nil
+	}
+
+	var result *ASTNode
+	first := len(ast.Members)
+	if i := ast.member(name); i >= 0 {
+		result, first = ast.Members[i], i
+	}
+
+	for j := 0; j < len(name); j++ {
+		if name[j] != '$' {
+			continue
+		}
+
+		i := ast.member(name[:j])
+		if i < 0 || i >= first {
+			continue
+		}
+		if found := findMember(ast.Members[i], name[j+1:]); found != nil {
+			result, first = found, i
+		}
+	}
+	return result
+}
+
+// quote returns s as a JSON string literal, escaping quotes, backslashes and
+// control characters.
+//
+// RFC 8259 - Section 7.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, c := range s {
+		switch c {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if c < 0x20 {
+				_, _ = fmt.Fprintf(&b, `\u%04x`, c)
+			} else {
+				b.WriteRune(c)
 			}
 		}
 	}
-	return false
+	b.WriteByte('"')
+	return b.String()
 }
 
-func prettyPrintImpl(writer io.Writer, ast *ASTNode, compact bool, depth int) {
+func prettyPrintImpl(writer io.Writer, ast *ASTNode, compact bool, depth int, member bool) {
 	lineTerm := "\n"
 	valueSep := "  "
 	if compact {
@@ -139,17 +335,18 @@ func prettyPrintImpl(writer io.Writer, ast *ASTNode, compact bool, depth int) {
 		}
 	}
 
+	// Members of objects are preceded by their names, which may be empty.
+	if member {
+		_, _ = fmt.Fprintf(writer, "%s: ", quote(ast.Name))
+	}
+
 	literal := ""
 	switch ast.Value {
 	case JSON_VALUE_OBJECT:
-		if ast.Name != "" && depth > 0 {
-			_, _ = fmt.Fprintf(writer, "\"%s\": {%s", ast.Name, lineTerm)
-		} else {
-			_, _ = fmt.Fprintf(writer, "{%s", lineTerm)
-		}
+		_, _ = fmt.Fprintf(writer, "{%s", lineTerm)
 
 		for i := 0; i < len(ast.Members); i++ {
-			prettyPrintImpl(writer, ast.Members[i], compact, depth+1)
+			prettyPrintImpl(writer, ast.Members[i], compact, depth+1, true)
 			if i < len(ast.Members)-1 {
 				_, _ = fmt.Fprintf(writer, ",%s", lineTerm)
 			}
@@ -160,21 +357,13 @@ func prettyPrintImpl(writer io.Writer, ast *ASTNode, compact bool, depth int) {
 			_, _ = fmt.Fprintf(writer, "%s", valueSep)
 		}
 
-		if depth == 0 {
-			_, _ = fmt.Fprintf(writer, "}%s", lineTerm)
-		} else {
-			_, _ = fmt.Fprintf(writer, "}%s", "")
-		}
+		_, _ = fmt.Fprintf(writer, "}")
 		return
 	case JSON_VALUE_ARRAY:
-		if ast.Name != "" && depth > 0 {
-			_, _ = fmt.Fprintf(writer, "\"%s\": [%s", ast.Name, lineTerm)
-		} else {
-			_, _ = fmt.Fprintf(writer, "[%s", lineTerm)
-		}
+		_, _ = fmt.Fprintf(writer, "[%s", lineTerm)
 
 		for i := 0; i < len(ast.Values); i++ {
-			prettyPrintImpl(writer, ast.Values[i], compact, depth+1)
+			prettyPrintImpl(writer, ast.Values[i], compact, depth+1, false)
 			if i < len(ast.Values)-1 {
 				_, _ = fmt.Fprintf(writer, ",%s", lineTerm)
 			}
@@ -185,21 +374,24 @@ func prettyPrintImpl(writer io.Writer, ast *ASTNode, compact bool, depth int) {
 			_, _ = fmt.Fprintf(writer, "%s", valueSep)
 		}
 
-		if depth == 0 {
-			_, _ = fmt.Fprintf(writer, "]%s", lineTerm)
-		} else {
-			_, _ = fmt.Fprintf(writer, "]")
-		}
-
+		_, _ = fmt.Fprintf(writer, "]")
 		return
 	case JSON_VALUE_NUMBER:
-		if ast.Name != "" && depth > 0 {
-			_, _ = fmt.Fprintf(writer, "\"%s\": ", ast.Name)
+		if ast.Literal != "" {
+			_, _ = fmt.Fprintf(writer, "%s", ast.Literal)
+			return
+		}
+
+		// JSON has no infinities or NaN, which SQLite can produce from
+		// arithmetic, so these are printed as null.
+		if math.IsInf(ast.Number, 0) || math.IsNaN(ast.Number) {
+			_, _ = fmt.Fprintf(writer, "null")
+			return
 		}
 
 		// This is not very pretty...
 		//
-		// Try and work ioOut what precision will recover the original number
+		// Try and work out what precision will recover the original number
 		// exactly and if we can't recover it, resort to %1.17g.
 		candidates := []string{"%1.15g", "%1.16g", "%1.17g"}
 		for i := 0; i < 3; i++ {
@@ -214,11 +406,7 @@ func prettyPrintImpl(writer io.Writer, ast *ASTNode, compact bool, depth int) {
 		_, _ = fmt.Fprintf(writer, "%1.17g", ast.Number)
 		return
 	case JSON_VALUE_STRING:
-		if ast.Name != "" && depth > 0 {
-			_, _ = fmt.Fprintf(writer, "\"%s\": \"%s\"", ast.Name, ast.String)
-		} else {
-			_, _ = fmt.Fprintf(writer, "\"%s\"", ast.String)
-		}
+		_, _ = fmt.Fprintf(writer, "%s", quote(ast.String))
 		return
 	case JSON_VALUE_NULL:
 		literal = "null"
@@ -231,14 +419,10 @@ func prettyPrintImpl(writer io.Writer, ast *ASTNode, compact bool, depth int) {
 	}
 
 	// Handle literal values.
-	if ast.Name != "" && depth > 0 {
-		_, _ = fmt.Fprintf(writer, "\"%s\": %s", ast.Name, literal)
-	} else {
-		_, _ = fmt.Fprintf(writer, "%s", literal)
-	}
+	_, _ = fmt.Fprintf(writer, "%s", literal)
 }
 
 // prettyPrint pretty prints an AST.
 func PrettyPrint(writer io.Writer, ast *ASTNode, compact bool) {
-	prettyPrintImpl(writer, ast, compact, 0)
+	prettyPrintImpl(writer, ast, compact, 0, false)
 }
@@ -0,0 +1,138 @@
+package json
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFindNode(t *testing.T) {
+	// Build a wide object, large enough for its members to be indexed.
+	wide := bytes.NewBufferString(`{"a": {"b": {"c": 1}}, "a$b": {"c": 2}, "d$e": 3, "x": 4`)
+	for i := 0; i < 2*memberIndexThreshold; i++ {
+		_, _ = fmt.Fprintf(wide, `, "k%d": {"v": %d}`, i, i)
+	}
+	wide.WriteString(`, "x": 5}`)
+
+	for _, size := range []string{"small", "wide"} {
+		json := `{"a": {"b": {"c": 1}}, "a$b": {"c": 2}, "d$e": 3, "x": 4, "x": 5}`
+		if size == "wide" {
+			json = wide.String()
+		}
+
+		parser := NewParser(NewTokenizer(strings.NewReader(json)))
+		records := parseRecords(t, parser)
+		if len(records) != 1 {
+			t.Fatalf("expected a single record, got %d", len(records))
+		}
+		root := records[0]
+
+		type TestCase struct {
+			name     string
+			expected string
+		}
+		cases := []TestCase{
+			{"a$b$c", "1"},
+			{"a$b", `{"c": 1}`},
+			{"a", `{"b": {"c": 1}}`},
+			{"d$e", "3"},
+			{"x", "4"},
+			{"a$c", ""},
+			{"a$b$c$d", ""},
+			{"missing", ""},
+		}
+		if size == "wide" {
+			cases = append(cases, TestCase{"k7$v", "7"})
+		}
+
+		for _, _case := range cases {
+			node := FindNode(root, _case.name)
+			result := ""
+			if node != nil {
+				buf := bytes.NewBuffer(nil)
+				PrettyPrint(buf, node, true)
+				result = buf.String()
+			}
+			if result != _case.expected {
+				t.Errorf("%s: expected %q for %s, got %q", size, _case.expected, _case.name, result)
+			}
+		}
+	}
+}
+
+func TestFindNode_Named(t *testing.T) {
+	parser := NewParser(NewTokenizer(strings.NewReader(`{"content": {"id": 1}}`)))
+	content := FindNode(parseRecords(t, parser)[0], "content")
+
+	if FindNode(content, "content") != content {
+		t.Errorf("expected the node itself")
+	}
+	if node := FindNode(content, "content$id"); node == nil || node.Literal != "1" {
+		t.Errorf("expected a nested member, got %v", node)
+	}
+	if node := FindNode(content, "id"); node != nil {
+		t.Errorf("expected no member, got %v", node)
+	}
+}
+
+func TestFindNode_AddedMembers(t *testing.T) {
+	object := &ASTNode{Value: JSON_VALUE_OBJECT}
+	for i := 0; i < memberIndexThreshold; i++ {
+		object.Members = append(object.Members, &ASTNode{Value: JSON_VALUE_NULL, Name: fmt.Sprintf("k%d", i)})
+	}
+	if FindNode(object, "k0") == nil {
+		t.Fatalf("expected a member")
+	}
+
+	// Members added after the index is built are still found.
+	object.Members = append(object.Members, &ASTNode{Value: JSON_VALUE_TRUE, Name: "added"})
+	if node := FindNode(object, "added"); node == nil || node.Value != JSON_VALUE_TRUE {
+		t.Errorf("expected the added member, got %v", node)
+	}
+}
+
+func TestFindAll(t *testing.T) {
+	json := `{
+		"orders": [
+			{"id": 1},
+			{"id": 2, "shipments": [{"packages": [{"n": 1}, {"n": 2}]}, {"packages": {"n": 3}}]},
+			{"id": 3, "shipments": [{}, {"packages": [{"n": 4}]}]}
+		],
+		"a/b": {"c~d": [5]}
+	}`
+	parser := NewParser(NewTokenizer(strings.NewReader(json)))
+	root := parseRecords(t, parser)[0]
+
+	type TestCase struct {
+		name     string
+		expected []string
+	}
+	cases := []TestCase{
+		{"orders$shipments$packages", []string{
+			`/orders/1/shipments/0/packages [{"n": 1},{"n": 2}]`,
+			`/orders/1/shipments/1/packages {"n": 3}`,
+			`/orders/2/shipments/1/packages [{"n": 4}]`,
+		}},
+		{"orders$id", []string{
+			`/orders/0/id 1`,
+			`/orders/1/id 2`,
+			`/orders/2/id 3`,
+		}},
+		{"a/b$c~d", []string{`/a~1b/c~0d [5]`}},
+		{"orders$missing", []string{}},
+	}
+
+	for _, _case := range cases {
+		matches := FindAll(root, _case.name)
+		results := make([]string, len(matches))
+		for i, match := range matches {
+			buf := bytes.NewBuffer(nil)
+			PrettyPrint(buf, match.Node, true)
+			results[i] = match.Pointer + " " + buf.String()
+		}
+		if strings.Join(results, "\n") != strings.Join(_case.expected, "\n") {
+			t.Errorf("expected %q for %s, got %q", _case.expected, _case.name, results)
+		}
+	}
+}
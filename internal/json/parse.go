@@ -1,162 +1,300 @@
 package json
 
+import "fmt"
+
+// Parser converts a stream of tokens into an AST.
+//
+// Tokens are either taken from Tokens or, for a Parser constructed with
+// NewParser, pulled from a Tokenizer on demand.
 type Parser struct {
 	Tokens []Token
 	pos    int
 	Ast    ASTNode
+
+	// Lines treats the input as newline-delimited JSON, where each line holds
+	// a single record.
+	Lines bool
+
+	tokenizer *Tokenizer
+	token     Token
+	prev      Token
+
+	// State for iterating over records with Next.
+	started   bool
+	inArray   bool
+	streaming bool       // Elements of the top-level array are records.
+	documents int        // Top-level values started so far.
+	pending   []*ASTNode // Elements of the top-level array read ahead.
 }
 
-func (p *Parser) cToken() Token {
-	return p.Tokens[p.pos]
+// readAhead is the number of elements of a leading top-level array held back
+// before its elements are returned as records, while it is not yet known
+// whether further documents follow it.
+const readAhead = 1024
+
+// NewParser creates a new Parser that pulls tokens from a Tokenizer.
+func NewParser(tokenizer *Tokenizer) *Parser {
+	return &Parser{
+		tokenizer: tokenizer,
+	}
 }
 
-func (p *Parser) next() (bool, Token) {
-	if p.pos >= len(p.Tokens) {
-		return false, Token{}
+// next advances to the next token.
+func (p *Parser) next() error {
+	p.prev = p.token
+	if p.tokenizer != nil {
+		token, err := p.tokenizer.Next()
+		p.token = token
+		return err
 	}
 
-	lPos := p.pos
+	if p.pos >= len(p.Tokens) {
+		p.token = Token{}
+		return nil
+	}
+	p.token = p.Tokens[p.pos]
 	p.pos++
-	return true, p.Tokens[lPos]
+	return nil
 }
 
-func (p *Parser) parseArray(root *ASTNode) {
-	for p.pos < len(p.Tokens) {
-		node := ASTNode{}
-		switch p.cToken().tokenType {
-		case JSON_TOKEN_RIGHT_SQUARE_BRACKET:
-			p.next()
-			return
-		case JSON_TOKEN_FALSE:
-			node.Value = JSON_VALUE_FALSE
-			_, _ = p.next()
-		case JSON_TOKEN_NULL:
-			node.Value = JSON_VALUE_FALSE
-			_, _ = p.next()
-		case JSON_TOKEN_TRUE:
-			node.Value = JSON_VALUE_TRUE
-			_, _ = p.next()
-		case JSON_TOKEN_LEFT_CURLY_BRACKET:
-			node.Value = JSON_VALUE_OBJECT
-			p.pos++
-			p.parseObject(&node)
-		case JSON_TOKEN_LEFT_SQUARE_BRACKET:
-			node.Value = JSON_VALUE_ARRAY
-			p.pos++
-			p.parseArray(&node)
-		case JSON_TOKEN_NUMBER:
-			node.Value = JSON_VALUE_NUMBER
-			node.Number = p.cToken().number
-			_, _ = p.next()
-		case JSON_TOKEN_STRING:
-			node.Value = JSON_VALUE_STRING
-			node.String = p.cToken().string
-			_, _ = p.next()
-		default:
-			panic("unexpected token\n")
+// unexpected returns a SyntaxError describing the current token.
+func (p *Parser) unexpected(format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	if p.tokenizer != nil {
+		return p.tokenizer.syntaxError(p.token, msg)
+	}
+
+	return &SyntaxError{
+		Msg:    msg,
+		Offset: p.token.offset,
+		Line:   p.token.line,
+		Column: p.token.column,
+		Token:  p.token.String(),
+	}
+}
+
+// parseValue parses the value starting at the current token into node.
+func (p *Parser) parseValue(node *ASTNode) error {
+	switch p.token.tokenType {
+	case JSON_TOKEN_FALSE:
+		node.Value = JSON_VALUE_FALSE
+		return p.next()
+	case JSON_TOKEN_NULL:
+		node.Value = JSON_VALUE_NULL
+		return p.next()
+	case JSON_TOKEN_TRUE:
+		node.Value = JSON_VALUE_TRUE
+		return p.next()
+	case JSON_TOKEN_LEFT_CURLY_BRACKET:
+		node.Value = JSON_VALUE_OBJECT
+		if err := p.next(); err != nil {
+			return err
+		}
+		return p.parseObject(node)
+	case JSON_TOKEN_LEFT_SQUARE_BRACKET:
+		node.Value = JSON_VALUE_ARRAY
+		if err := p.next(); err != nil {
+			return err
+		}
+		return p.parseArray(node)
+	case JSON_TOKEN_NUMBER:
+		node.Value = JSON_VALUE_NUMBER
+		node.Number = p.token.number
+		node.Literal = p.token.string
+		return p.next()
+	case JSON_TOKEN_STRING:
+		node.Value = JSON_VALUE_STRING
+		node.String = p.token.string
+		return p.next()
+	default:
+		return p.unexpected("expected a value, got %s", p.token)
+	}
+}
+
+// parseArray parses array values up to and including the closing bracket.
+func (p *Parser) parseArray(root *ASTNode) error {
+	for p.token.tokenType != JSON_TOKEN_RIGHT_SQUARE_BRACKET {
+		node := &ASTNode{}
+		if err := p.parseValue(node); err != nil {
+			return err
 		}
+		root.Values = append(root.Values, node)
 
-		root.Values = append(root.Values, &node)
-		if p.cToken().tokenType == JSON_TOKEN_COMMA {
-			p.pos++
+		if p.token.tokenType == JSON_TOKEN_COMMA {
+			if err := p.next(); err != nil {
+				return err
+			}
+			if p.token.tokenType == JSON_TOKEN_RIGHT_SQUARE_BRACKET {
+				return p.unexpected("expected a value, got %s", p.token)
+			}
 			continue
-		} else if p.cToken().tokenType == JSON_TOKEN_RIGHT_SQUARE_BRACKET {
-			p.pos++
-			break
+		} else if p.token.tokenType != JSON_TOKEN_RIGHT_SQUARE_BRACKET {
+			return p.unexpected("expected ',' or ']', got %s", p.token)
 		}
-		panic("unexpected token")
 	}
+	return p.next()
 }
 
-func (p *Parser) parseObject(root *ASTNode) {
-	for p.pos < len(p.Tokens) {
-		if p.cToken().tokenType == JSON_TOKEN_RIGHT_CURLY_BRACKET {
-			return
-		}
-
-		member := ASTNode{}
-		if p.cToken().tokenType != JSON_TOKEN_STRING {
-			panic("expected a string\n")
-		}
-		member.Name = p.cToken().string
-		p.next()
-
-		if p.cToken().tokenType != JSON_TOKEN_COLON {
-			panic("expected a separator\n")
-		}
-		p.next()
-
-		switch p.cToken().tokenType {
-		case JSON_TOKEN_RIGHT_CURLY_BRACKET:
-			p.next()
-			return
-		case JSON_TOKEN_FALSE:
-			member.Value = JSON_VALUE_FALSE
-			_, _ = p.next()
-		case JSON_TOKEN_NULL:
-			member.Value = JSON_VALUE_NULL
-			_, _ = p.next()
-		case JSON_TOKEN_TRUE:
-			member.Value = JSON_VALUE_TRUE
-			_, _ = p.next()
-		case JSON_TOKEN_LEFT_CURLY_BRACKET:
-			member.Value = JSON_VALUE_OBJECT
-			p.next()
-			p.parseObject(&member)
-		case JSON_TOKEN_LEFT_SQUARE_BRACKET:
-			member.Value = JSON_VALUE_ARRAY
-			p.next()
-			p.parseArray(&member)
-		case JSON_TOKEN_NUMBER:
-			member.Value = JSON_VALUE_NUMBER
-			member.Number = p.cToken().number
-			_, _ = p.next()
-		case JSON_TOKEN_STRING:
-			member.Value = JSON_VALUE_STRING
-			member.String = p.cToken().string
-			_, _ = p.next()
-		default:
-			panic("unexpected token\n")
+// parseObject parses object members up to and including the closing bracket.
+func (p *Parser) parseObject(root *ASTNode) error {
+	for p.token.tokenType != JSON_TOKEN_RIGHT_CURLY_BRACKET {
+		member := &ASTNode{}
+		if p.token.tokenType != JSON_TOKEN_STRING {
+			return p.unexpected("expected a member name, got %s", p.token)
+		}
+		member.Name = p.token.string
+		if err := p.next(); err != nil {
+			return err
 		}
 
-		root.Members = append(root.Members, &member)
-		if p.cToken().tokenType == JSON_TOKEN_COMMA {
-			p.pos++
+		if p.token.tokenType != JSON_TOKEN_COLON {
+			return p.unexpected("expected ':', got %s", p.token)
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+
+		if err := p.parseValue(member); err != nil {
+			return err
+		}
+		root.Members = append(root.Members, member)
+
+		if p.token.tokenType == JSON_TOKEN_COMMA {
+			if err := p.next(); err != nil {
+				return err
+			}
+			if p.token.tokenType == JSON_TOKEN_RIGHT_CURLY_BRACKET {
+				return p.unexpected("expected a member name, got %s", p.token)
+			}
 			continue
-		} else if p.cToken().tokenType == JSON_TOKEN_RIGHT_CURLY_BRACKET {
-			p.pos++
-			break
+		} else if p.token.tokenType != JSON_TOKEN_RIGHT_CURLY_BRACKET {
+			return p.unexpected("expected ',' or '}', got %s", p.token)
 		}
-		panic("unexpected token")
 	}
+	return p.next()
 }
 
-func (p *Parser) Parse() {
-	switch _, token := p.next(); token.tokenType {
-	case JSON_TOKEN_FALSE:
-		p.Ast.Value = JSON_VALUE_FALSE
-		return
-	case JSON_TOKEN_NULL:
-		p.Ast.Value = JSON_VALUE_NULL
-		return
-	case JSON_TOKEN_TRUE:
-		p.Ast.Value = JSON_VALUE_TRUE
-		return
-	case JSON_TOKEN_LEFT_CURLY_BRACKET:
-		p.Ast.Value = JSON_VALUE_OBJECT
-		p.parseObject(&p.Ast)
-	case JSON_TOKEN_LEFT_SQUARE_BRACKET:
-		p.Ast.Value = JSON_VALUE_ARRAY
-		p.parseArray(&p.Ast)
-	case JSON_TOKEN_NUMBER:
-		p.Ast.Value = JSON_VALUE_NUMBER
-		p.Ast.Number = token.number
-		return
-	case JSON_TOKEN_STRING:
-		p.Ast.Value = JSON_VALUE_STRING
-		p.Ast.String = token.string
-		return
-	default:
-		panic("unexpected token\n")
+// Parse parses a single top-level value into Ast.
+func (p *Parser) Parse() error {
+	if err := p.next(); err != nil {
+		return err
+	}
+	return p.parseValue(&p.Ast)
+}
+
+// Next returns the next record from the input, or nil once the input is
+// exhausted.
+//
+// The input may contain any number of consecutive top-level values, optionally
+// separated by whitespace, each of which is returned as a single record. When
+// the input is a single top-level array, its elements are returned as records
+// instead, parsed one at a time so that only a few elements need to be held in
+// memory. Since an array can only be known to be the whole input once it ends,
+// further documents after a top-level array of more than readAhead elements
+// are an error. In Lines mode, the value on each line is returned as a record
+// as is.
+func (p *Parser) Next() (*ASTNode, error) {
+	if !p.started {
+		p.started = true
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.Lines {
+		return p.nextLine()
+	}
+
+	for {
+		if len(p.pending) > 0 && (p.streaming || !p.inArray) {
+			node := p.pending[0]
+			p.pending = p.pending[1:]
+			return node, nil
+		}
+
+		if p.inArray {
+			if p.token.tokenType == JSON_TOKEN_RIGHT_SQUARE_BRACKET {
+				if err := p.next(); err != nil {
+					return nil, err
+				}
+				p.inArray = false
+				if !p.streaming && p.token.tokenType != JSON_TOKEN_NONE {
+					node := &ASTNode{Value: JSON_VALUE_ARRAY, Values: p.pending}
+					p.pending = nil
+					return node, nil
+				}
+				p.streaming = true
+				continue
+			}
+
+			node := &ASTNode{}
+			if err := p.parseValue(node); err != nil {
+				return nil, err
+			}
+			if p.token.tokenType == JSON_TOKEN_COMMA {
+				if err := p.next(); err != nil {
+					return nil, err
+				}
+				if p.token.tokenType == JSON_TOKEN_RIGHT_SQUARE_BRACKET {
+					return nil, p.unexpected("expected a value, got %s", p.token)
+				}
+			} else if p.token.tokenType != JSON_TOKEN_RIGHT_SQUARE_BRACKET {
+				return nil, p.unexpected("expected ',' or ']', got %s", p.token)
+			}
+			if p.streaming {
+				return node, nil
+			}
+			p.pending = append(p.pending, node)
+			p.streaming = len(p.pending) > readAhead
+			continue
+		}
+
+		if p.token.tokenType == JSON_TOKEN_NONE {
+			return nil, nil
+		}
+		if p.streaming {
+			return nil, p.unexpected("expected end of input after a top-level array, got %s", p.token)
+		}
+
+		p.documents++
+		switch p.token.tokenType {
+		case JSON_TOKEN_LEFT_SQUARE_BRACKET:
+			if p.documents > 1 {
+				node := &ASTNode{}
+				if err := p.parseValue(node); err != nil {
+					return nil, err
+				}
+				return node, nil
+			}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			p.inArray = true
+		case JSON_TOKEN_LEFT_CURLY_BRACKET, JSON_TOKEN_STRING, JSON_TOKEN_NUMBER,
+			JSON_TOKEN_TRUE, JSON_TOKEN_FALSE, JSON_TOKEN_NULL:
+			node := &ASTNode{}
+			if err := p.parseValue(node); err != nil {
+				return nil, err
+			}
+			return node, nil
+		default:
+			return nil, p.unexpected("expected a new document, got %s", p.token)
+		}
+	}
+}
+
+// nextLine returns the record on the next non-empty line of the input.
+func (p *Parser) nextLine() (*ASTNode, error) {
+	if p.token.tokenType == JSON_TOKEN_NONE {
+		return nil, nil
+	}
+
+	node := &ASTNode{}
+	if err := p.parseValue(node); err != nil {
+		return nil, err
+	}
+	if p.token.tokenType != JSON_TOKEN_NONE && p.token.line == p.prev.line {
+		return nil, p.unexpected("expected a newline between records, got %s", p.token)
 	}
+	return node, nil
 }
@@ -1,6 +1,10 @@
 package json
 
-import "testing"
+import (
+	"math"
+	"strings"
+	"testing"
+)
 
 type ParseTestCase struct {
 	tokens []Token
@@ -102,10 +106,404 @@ func TestParse(t *testing.T) {
 		parser := Parser{
 			Tokens: parseTestCases[i].tokens,
 		}
-		parser.Parse()
+		if err := parser.Parse(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
 
 		if !equal(&parser.Ast, &parseTestCases[i].ast) {
 			t.Fatal("unexpected AST")
 		}
 	}
 }
+
+// parseRecords is a helper method to read all records from a Parser.
+func parseRecords(t *testing.T, parser *Parser) []*ASTNode {
+	var records []*ASTNode
+	for {
+		record, err := parser.Next()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if record == nil {
+			return records
+		}
+		records = append(records, record)
+	}
+}
+
+func TestParse_Next(t *testing.T) {
+	type TestCase struct {
+		json    string
+		records []ASTNode
+	}
+
+	cases := []TestCase{
+		{
+			json:    `[]`,
+			records: nil,
+		},
+		{
+			json: `{"a": 1}`,
+			records: []ASTNode{
+				{
+					Value: JSON_VALUE_OBJECT,
+					Members: []*ASTNode{
+						{Name: "a", Value: JSON_VALUE_NUMBER, Number: 1},
+					},
+				},
+			},
+		},
+		{
+			json: `[{"a": 1}, {"a": [2, null]}, "b"]`,
+			records: []ASTNode{
+				{
+					Value: JSON_VALUE_OBJECT,
+					Members: []*ASTNode{
+						{Name: "a", Value: JSON_VALUE_NUMBER, Number: 1},
+					},
+				},
+				{
+					Value: JSON_VALUE_OBJECT,
+					Members: []*ASTNode{
+						{
+							Name:  "a",
+							Value: JSON_VALUE_ARRAY,
+							Values: []*ASTNode{
+								{Value: JSON_VALUE_NUMBER, Number: 2},
+								{Value: JSON_VALUE_NULL},
+							},
+						},
+					},
+				},
+				{
+					Value:  JSON_VALUE_STRING,
+					String: "b",
+				},
+			},
+		},
+		{
+			json: `{"a": 1}{"a": 2}  3`,
+			records: []ASTNode{
+				{
+					Value: JSON_VALUE_OBJECT,
+					Members: []*ASTNode{
+						{Name: "a", Value: JSON_VALUE_NUMBER, Number: 1},
+					},
+				},
+				{
+					Value: JSON_VALUE_OBJECT,
+					Members: []*ASTNode{
+						{Name: "a", Value: JSON_VALUE_NUMBER, Number: 2},
+					},
+				},
+				{
+					Value:  JSON_VALUE_NUMBER,
+					Number: 3,
+				},
+			},
+		},
+		{
+			json: "[1, 2]\n[]\n[3]",
+			records: []ASTNode{
+				{
+					Value: JSON_VALUE_ARRAY,
+					Values: []*ASTNode{
+						{Value: JSON_VALUE_NUMBER, Number: 1},
+						{Value: JSON_VALUE_NUMBER, Number: 2},
+					},
+				},
+				{Value: JSON_VALUE_ARRAY},
+				{
+					Value: JSON_VALUE_ARRAY,
+					Values: []*ASTNode{
+						{Value: JSON_VALUE_NUMBER, Number: 3},
+					},
+				},
+			},
+		},
+		{
+			json: "{\"a\": 1} [2]",
+			records: []ASTNode{
+				{
+					Value: JSON_VALUE_OBJECT,
+					Members: []*ASTNode{
+						{Name: "a", Value: JSON_VALUE_NUMBER, Number: 1},
+					},
+				},
+				{
+					Value: JSON_VALUE_ARRAY,
+					Values: []*ASTNode{
+						{Value: JSON_VALUE_NUMBER, Number: 2},
+					},
+				},
+			},
+		},
+	}
+
+	for _, _case := range cases {
+		parser := NewParser(NewTokenizer(strings.NewReader(_case.json)))
+
+		records := parseRecords(t, parser)
+
+		if len(records) != len(_case.records) {
+			t.Fatalf("unexpected number of records")
+		}
+		for i := 0; i < len(records); i++ {
+			if !equal(records[i], &_case.records[i]) {
+				t.Fatalf("unexpected record")
+			}
+		}
+	}
+}
+
+func TestParse_NextLines(t *testing.T) {
+	json := "{\"a\": 1}\n\n[2, 3]\n\"b\"\n"
+
+	parser := NewParser(NewTokenizer(strings.NewReader(json)))
+	parser.Lines = true
+
+	expected := []ASTNode{
+		{
+			Value: JSON_VALUE_OBJECT,
+			Members: []*ASTNode{
+				{Name: "a", Value: JSON_VALUE_NUMBER, Number: 1},
+			},
+		},
+		{
+			Value: JSON_VALUE_ARRAY,
+			Values: []*ASTNode{
+				{Value: JSON_VALUE_NUMBER, Number: 2},
+				{Value: JSON_VALUE_NUMBER, Number: 3},
+			},
+		},
+		{
+			Value:  JSON_VALUE_STRING,
+			String: "b",
+		},
+	}
+
+	records := parseRecords(t, parser)
+
+	if len(records) != len(expected) {
+		t.Fatalf("unexpected number of records")
+	}
+	for i := 0; i < len(records); i++ {
+		if !equal(records[i], &expected[i]) {
+			t.Fatalf("unexpected record")
+		}
+	}
+}
+
+func TestParse_SyntaxError(t *testing.T) {
+	type TestCase struct {
+		json    string
+		lines   bool
+		line    int
+		column  int
+		token   string
+		snippet string
+		offset  int
+	}
+
+	cases := []TestCase{
+		{
+			json:    "[\n  {\"a\": 1,\n   \"b\" 2}\n]",
+			line:    3,
+			column:  8,
+			token:   "2",
+			snippet: `   "b" 2}`,
+			offset:  7,
+		},
+		{
+			json:    `{"a": [1, 2}`,
+			line:    1,
+			column:  12,
+			token:   "}",
+			snippet: `{"a": [1, 2}`,
+			offset:  11,
+		},
+		{
+			json:    `{"a": tru}`,
+			line:    1,
+			column:  7,
+			token:   "tru}",
+			snippet: `{"a": tru}`,
+			offset:  6,
+		},
+		{
+			json:    `{"a": "unterminated`,
+			line:    1,
+			column:  7,
+			token:   `"unterminated"`,
+			snippet: `{"a": "unterminated`,
+			offset:  6,
+		},
+		{
+			json:    `{"a": "x\q"}`,
+			line:    1,
+			column:  7,
+			token:   `\q`,
+			snippet: `{"a": "x\q"}`,
+			offset:  6,
+		},
+		{
+			json:    `{"a": "\u12g4"}`,
+			line:    1,
+			column:  7,
+			token:   `\u12g4`,
+			snippet: `{"a": "\u12g4"}`,
+			offset:  6,
+		},
+		{
+			json:    "{\"a\": \"x\ty\"}",
+			line:    1,
+			column:  7,
+			token:   "\t",
+			snippet: "{\"a\": \"x\ty\"}",
+			offset:  6,
+		},
+		{
+			json:    `{"a": 1} , {"a": 2}`,
+			line:    1,
+			column:  10,
+			token:   ",",
+			snippet: `{"a": 1} , {"a": 2}`,
+			offset:  9,
+		},
+		{
+			json:    `[1, 2,]`,
+			line:    1,
+			column:  7,
+			token:   "]",
+			snippet: `[1, 2,]`,
+			offset:  6,
+		},
+		{
+			json:    `[{"a": [1, 2, ]}]`,
+			line:    1,
+			column:  15,
+			token:   "]",
+			snippet: `[{"a": [1, 2, ]}]`,
+			offset:  14,
+		},
+		{
+			json:    "{\"a\": 1,\n}",
+			line:    2,
+			column:  1,
+			token:   "}",
+			snippet: "}",
+			offset:  0,
+		},
+		{
+			json:    `{"a": 1} @`,
+			line:    1,
+			column:  10,
+			token:   "@",
+			snippet: `{"a": 1} @`,
+			offset:  9,
+		},
+		{
+			json:    "{\"a\": 1}\n{\"a\": 2} {\"a\": 3}\n",
+			lines:   true,
+			line:    2,
+			column:  10,
+			token:   "{",
+			snippet: `{"a": 2} {"a": 3}`,
+			offset:  9,
+		},
+		{
+			json:    "{\"a\":1,\r",
+			line:    1,
+			column:  9,
+			token:   "end of input",
+			snippet: `{"a":1,`,
+			offset:  7,
+		},
+		{
+			json:    "[" + strings.Repeat("1, ", readAhead) + "1]\n{}",
+			line:    2,
+			column:  1,
+			token:   "{",
+			snippet: "{}",
+			offset:  0,
+		},
+	}
+
+	for _, _case := range cases {
+		parser := NewParser(NewTokenizer(strings.NewReader(_case.json)))
+		parser.Lines = _case.lines
+
+		var err error
+		for {
+			var record *ASTNode
+			record, err = parser.Next()
+			if err != nil || record == nil {
+				break
+			}
+		}
+
+		syntaxError, ok := err.(*SyntaxError)
+		if !ok {
+			t.Fatalf("expected a syntax error for %s", _case.json)
+		}
+		if syntaxError.Line != _case.line || syntaxError.Column != _case.column {
+			t.Errorf("unexpected position: got %d:%d, expected %d:%d",
+				syntaxError.Line, syntaxError.Column, _case.line, _case.column)
+		}
+		if syntaxError.Token != _case.token {
+			t.Errorf("unexpected token: got %s, expected %s", syntaxError.Token, _case.token)
+		}
+		if syntaxError.Snippet != _case.snippet || syntaxError.SnippetOffset != _case.offset {
+			t.Errorf("unexpected snippet: got %q at %d, expected %q at %d",
+				syntaxError.Snippet, syntaxError.SnippetOffset, _case.snippet, _case.offset)
+		}
+	}
+}
+
+func TestParse_PrettyPrintNumbers(t *testing.T) {
+	json := `{"id": 1234567890123456789,"big": 12345678901234567890123,"price": 1.50,"exp": 1E+3,"huge": 1e400}`
+
+	parser := NewParser(NewTokenizer(strings.NewReader(json)))
+	if err := parser.Parse(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Numbers should be printed exactly as they appeared in the input.
+	var buf strings.Builder
+	PrettyPrint(&buf, &parser.Ast, true)
+	if buf.String() != json {
+		t.Fatalf("expected %s, got %s", json, buf.String())
+	}
+}
+
+func TestPrettyPrint_NonFiniteNumbers(t *testing.T) {
+	ast := ASTNode{Value: JSON_VALUE_ARRAY, Values: []*ASTNode{
+		{Value: JSON_VALUE_NUMBER, Number: math.Inf(1)},
+		{Value: JSON_VALUE_NUMBER, Number: math.Inf(-1)},
+		{Value: JSON_VALUE_NUMBER, Number: math.NaN()},
+		{Value: JSON_VALUE_NUMBER, Number: 1.5},
+	}}
+
+	// Infinities and NaN are not JSON, so should be printed as null.
+	var buf strings.Builder
+	PrettyPrint(&buf, &ast, true)
+	if expected := "[null,null,null,1.5]"; buf.String() != expected {
+		t.Fatalf("expected %s, got %s", expected, buf.String())
+	}
+}
+
+func TestParse_PrettyPrintEmptyNames(t *testing.T) {
+	json := `{"": 1,"a": {"": [{"": "x","b": null}]},"": true}`
+
+	parser := NewParser(NewTokenizer(strings.NewReader(json)))
+	if err := parser.Parse(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Members with empty names should be printed with them.
+	var buf strings.Builder
+	PrettyPrint(&buf, &parser.Ast, true)
+	if buf.String() != json {
+		t.Fatalf("expected %s, got %s", json, buf.String())
+	}
+}
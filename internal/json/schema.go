@@ -1,10 +1,117 @@
 package json
 
-import "github.com/progbits/sqjson/internal/util"
+import (
+	"bytes"
+	"strings"
+)
 
+// Maximum number of distinct example values kept for each column.
+const maxExamples = 3
+
+// Schema describes the columns of a table of JSON values.
 type Schema struct {
-	CreateTableStmt string
-	Columns         []string
+	// Name of the table, being the $-joined path of a nested table and empty
+	// for the top-level table.
+	Name string
+
+	Columns []string
+
+	// Statistics for each of Columns, in the same order.
+	Stats []*ColumnStats
+
+	// Number of rows added to the schema.
+	Rows int
+
+	// Nested tables of rows added with AddNested, in the order they were
+	// first seen.
+	Tables []*Schema
+
+	// Index of each column by lower case name, as SQLite column names are not
+	// case sensitive.
+	index map[string]int
+
+	// Index of each nested table by name.
+	tableIndex map[string]int
+}
+
+// ColumnStats summarises the values observed for a column.
+type ColumnStats struct {
+	// Number of values of each type, keyed by type name.
+	Types map[string]int
+
+	// Number of rows containing the column, including those where it is null.
+	Present int
+
+	// Distinct example values of the column.
+	Examples []*ASTNode
+
+	exampleText []string
+}
+
+// TypeName returns the name of the type of a value, as used in ColumnStats.
+func TypeName(value JSONValue) string {
+	switch value {
+	case JSON_VALUE_OBJECT:
+		return "object"
+	case JSON_VALUE_ARRAY:
+		return "array"
+	case JSON_VALUE_NUMBER:
+		return "number"
+	case JSON_VALUE_STRING:
+		return "string"
+	case JSON_VALUE_NULL:
+		return "null"
+	case JSON_VALUE_TRUE, JSON_VALUE_FALSE:
+		return "boolean"
+	default:
+		panic("unexpected value\n")
+	}
+}
+
+// Add adds the columns of a row to the schema. Columns already present are
+// ignored, so adding each row of a table gives the union of their columns.
+func (s *Schema) Add(row *ASTNode) {
+	if row == nil {
+		return
+	}
+
+	s.Rows++
+	if row.Value != JSON_VALUE_OBJECT {
+		return
+	}
+
+	if s.index == nil {
+		s.index = make(map[string]int)
+	}
+
+	// Rows of nested tables may be named object members, but their columns
+	// are named relative to the row itself.
+	for i := 0; i < len(row.Members); i++ {
+		collectColumns(row.Members[i], s, "")
+	}
+}
+
+// AddNested adds a row to the schema like Add, and adds the elements of each
+// array within the row as rows of the nested table named by its path.
+func (s *Schema) AddNested(row *ASTNode) {
+	s.Add(row)
+	collectTables(row, s, "")
+}
+
+// table returns the nested table with the given name, adding it to the schema
+// if not already present.
+func (s *Schema) table(name string) *Schema {
+	if s.tableIndex == nil {
+		s.tableIndex = make(map[string]int)
+	}
+
+	i, ok := s.tableIndex[name]
+	if !ok {
+		i = len(s.Tables)
+		s.tableIndex[name] = i
+		s.Tables = append(s.Tables, &Schema{Name: name})
+	}
+	return s.Tables[i]
 }
 
 // Concatenate a prefix an a member name.
@@ -20,6 +127,39 @@ func concatPrefixName(prefix, name string) string {
 	return prefix + "$" + name
 }
 
+// addColumn adds a column to the schema if not already present, and records
+// the value of the column in the current row.
+func (s *Schema) addColumn(name string, value *ASTNode) {
+	if name == "" {
+		return
+	}
+
+	i, ok := s.index[strings.ToLower(name)]
+	if !ok {
+		i = len(s.Columns)
+		s.index[strings.ToLower(name)] = i
+		s.Columns = append(s.Columns, name)
+		s.Stats = append(s.Stats, &ColumnStats{Types: make(map[string]int)})
+	}
+
+	stats := s.Stats[i]
+	stats.Present++
+	stats.Types[TypeName(value.Value)]++
+	if len(stats.Examples) == maxExamples || value.Value == JSON_VALUE_NULL {
+		return
+	}
+
+	buf := bytes.NewBuffer(nil)
+	PrettyPrint(buf, value, true)
+	for _, text := range stats.exampleText {
+		if text == buf.String() {
+			return
+		}
+	}
+	stats.Examples = append(stats.Examples, value)
+	stats.exampleText = append(stats.exampleText, buf.String())
+}
+
 func collectColumns(ast *ASTNode, schema *Schema, prefix string) {
 	if ast == nil {
 		return
@@ -29,8 +169,7 @@ func collectColumns(ast *ASTNode, schema *Schema, prefix string) {
 	if ast.Value == JSON_VALUE_OBJECT {
 		// Register named objects themselves as a column.
 		if ast.Name != "" {
-			escaped := util.EscapeString(columnName)
-			schema.Columns = append(schema.Columns, escaped)
+			schema.addColumn(columnName, ast)
 		}
 
 		// Register the objects members as columns, prefixed with the
@@ -40,6 +179,27 @@ func collectColumns(ast *ASTNode, schema *Schema, prefix string) {
 		}
 		return
 	}
-	escaped := util.EscapeString(columnName)
-	schema.Columns = append(schema.Columns, escaped)
+	schema.addColumn(columnName, ast)
+}
+
+func collectTables(ast *ASTNode, schema *Schema, prefix string) {
+	if ast == nil || ast.Value != JSON_VALUE_OBJECT {
+		return
+	}
+
+	for _, member := range ast.Members {
+		name := concatPrefixName(prefix, member.Name)
+		switch member.Value {
+		case JSON_VALUE_OBJECT:
+			collectTables(member, schema, name)
+		case JSON_VALUE_ARRAY:
+			// Arrays along a path are expanded, so the tables nested in each
+			// element share the path of the array itself.
+			table := schema.table(name)
+			for _, value := range member.Values {
+				table.Add(value)
+				collectTables(value, schema, name)
+			}
+		}
+	}
 }
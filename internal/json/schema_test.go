@@ -0,0 +1,96 @@
+package json
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSchema_Add(t *testing.T) {
+	json := `
+		{"id": 1, "name": "velit", "about": {"score": 0.5, "tags": ["a"]}}
+		{"id": 2, "ID": 3, "extra": null}
+		42
+		{"about": {"score": 1, "rank": 2}}
+	`
+	expected := []string{"id", "name", "about", "about$score", "about$tags", "extra", "about$rank"}
+
+	var schema Schema
+	parser := NewParser(NewTokenizer(strings.NewReader(json)))
+	for _, record := range parseRecords(t, parser) {
+		schema.Add(record)
+	}
+
+	if len(schema.Columns) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, schema.Columns)
+	}
+	for i := 0; i < len(expected); i++ {
+		if schema.Columns[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, schema.Columns)
+		}
+	}
+}
+
+func TestSchema_Stats(t *testing.T) {
+	json := `[
+		{"id": 1, "name": "velit"},
+		{"id": 2, "name": null},
+		{"id": "3"},
+		{"id": 1}
+	]`
+
+	var schema Schema
+	parser := NewParser(NewTokenizer(strings.NewReader(json)))
+	for _, record := range parseRecords(t, parser) {
+		schema.Add(record)
+	}
+
+	if schema.Rows != 4 {
+		t.Fatalf("expected 4 rows, got %d", schema.Rows)
+	}
+
+	id := schema.Stats[0]
+	if id.Present != 4 || id.Types["number"] != 3 || id.Types["string"] != 1 {
+		t.Fatalf("unexpected stats for id: %+v", id)
+	}
+	if len(id.Examples) != 3 || id.Examples[2].String != "3" {
+		t.Fatalf("expected 3 distinct examples for id")
+	}
+
+	name := schema.Stats[1]
+	if name.Present != 2 || name.Types["null"] != 1 || len(name.Examples) != 1 {
+		t.Fatalf("unexpected stats for name: %+v", name)
+	}
+}
+
+func TestSchema_AddNested(t *testing.T) {
+	json := `
+		{"id": 1, "orders": [{"n": 1, "shipments": [{"w": 2}]}, {"n": 2}]}
+		{"about": {"tags": ["a", "b"]}, "orders": [{"n": 3, "shipments": []}]}
+	`
+	type table struct {
+		name    string
+		rows    int
+		columns []string
+	}
+	expected := []table{
+		{"orders", 3, []string{"n", "shipments"}},
+		{"orders$shipments", 1, []string{"w"}},
+		{"about$tags", 2, nil},
+	}
+
+	var schema Schema
+	parser := NewParser(NewTokenizer(strings.NewReader(json)))
+	for _, record := range parseRecords(t, parser) {
+		schema.AddNested(record)
+	}
+
+	if schema.Rows != 2 || len(schema.Tables) != len(expected) {
+		t.Fatalf("unexpected tables: %+v", schema.Tables)
+	}
+	for i, table := range schema.Tables {
+		if table.Name != expected[i].name || table.Rows != expected[i].rows ||
+			strings.Join(table.Columns, ",") != strings.Join(expected[i].columns, ",") {
+			t.Fatalf("expected %+v, got %+v", expected[i], table)
+		}
+	}
+}
@@ -1,8 +1,15 @@
 package json
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"strconv"
 	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
 type TokenType int
@@ -24,104 +31,433 @@ const (
 
 type Token struct {
 	tokenType TokenType
-	string    string
+	string    string // Decoded value of strings, or the literal text of numbers.
 	number    float64
+	offset    int // Byte offset of the start of the token.
+	line      int // Line on which the token starts, counting from 1.
+	column    int // Column at which the token starts, counting from 1.
 }
 
+// String returns the token as it appeared in the input.
+func (t Token) String() string {
+	switch t.tokenType {
+	case JSON_TOKEN_NONE:
+		return "end of input"
+	case JSON_TOKEN_LEFT_SQUARE_BRACKET:
+		return "["
+	case JSON_TOKEN_LEFT_CURLY_BRACKET:
+		return "{"
+	case JSON_TOKEN_RIGHT_SQUARE_BRACKET:
+		return "]"
+	case JSON_TOKEN_RIGHT_CURLY_BRACKET:
+		return "}"
+	case JSON_TOKEN_COLON:
+		return ":"
+	case JSON_TOKEN_COMMA:
+		return ","
+	case JSON_TOKEN_FALSE:
+		return "false"
+	case JSON_TOKEN_NULL:
+		return "null"
+	case JSON_TOKEN_TRUE:
+		return "true"
+	case JSON_TOKEN_NUMBER:
+		return t.string
+	case JSON_TOKEN_STRING:
+		return quote(t.string)
+	default:
+		panic("unexpected token\n")
+	}
+}
+
+// SyntaxError describes malformed JSON input.
+type SyntaxError struct {
+	Msg    string
+	Offset int    // Byte offset of the offending token.
+	Line   int    // Line of the offending token, counting from 1.
+	Column int    // Column of the offending token, counting from 1.
+	Token  string // The offending token.
+
+	// Snippet holds the input surrounding the offending token, which starts
+	// SnippetOffset bytes into the snippet.
+	Snippet       string
+	SnippetOffset int
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// Number of bytes either side of an error to include in its snippet.
+const snippetContext = 40
+
+// Tokenizer converts JSON text into a stream of tokens.
+//
+// A Tokenizer can either be constructed with the complete input in Buf and
+// run to completion with Tokenize, or constructed with NewTokenizer to pull
+// tokens one at a time from an io.Reader with Next. The latter only buffers
+// as much of the input as is required to produce the current token.
 type Tokenizer struct {
 	Buf    string
 	pos    int
+	line   int
+	column int
 	Tokens []Token
+
+	reader  *bufio.Reader
+	current []byte // The most recently read bytes of the current line.
 }
 
-func (t *Tokenizer) Tokenize() {
-	for t.pos < len(t.Buf) {
-		// Skip whitespace.
-		c := t.Buf[t.pos]
-		if c == 0x20 || c == 0x09 || c == 0x0A || c == 0x0D {
-			t.pos++
-			continue
+// NewTokenizer creates a new Tokenizer reading from r.
+func NewTokenizer(r io.Reader) *Tokenizer {
+	return &Tokenizer{
+		reader: bufio.NewReader(r),
+	}
+}
+
+// Tokenize consumes the remaining input, appending each token to Tokens.
+func (t *Tokenizer) Tokenize() error {
+	for {
+		token, err := t.Next()
+		if err != nil {
+			return err
+		}
+		if token.tokenType == JSON_TOKEN_NONE {
+			return nil
 		}
+		t.Tokens = append(t.Tokens, token)
+	}
+}
 
-		// Handle structural characters.
-		token := Token{}
-		switch c {
-		case '[':
-			token.tokenType = JSON_TOKEN_LEFT_SQUARE_BRACKET
-		case '{':
-			token.tokenType = JSON_TOKEN_LEFT_CURLY_BRACKET
-		case ']':
-			token.tokenType = JSON_TOKEN_RIGHT_SQUARE_BRACKET
-		case '}':
-			token.tokenType = JSON_TOKEN_RIGHT_CURLY_BRACKET
-		case ':':
-			token.tokenType = JSON_TOKEN_COLON
-		case ',':
-			token.tokenType = JSON_TOKEN_COMMA
-		}
-
-		if token.tokenType != JSON_TOKEN_NONE {
-			t.Tokens = append(t.Tokens, token)
-			t.pos++
-			continue
+// readByte reads the next byte from the input, returning false at EOF.
+func (t *Tokenizer) readByte() (byte, bool) {
+	c, err := t.reader.ReadByte()
+	if err != nil {
+		return 0, false
+	}
+
+	t.pos++
+	if c == '\n' {
+		t.line++
+		t.column = 0
+		t.current = t.current[:0]
+		return c, true
+	}
+
+	t.column++
+	if len(t.current) == 2*snippetContext {
+		t.current = t.current[:copy(t.current, t.current[snippetContext:])]
+	}
+	t.current = append(t.current, c)
+	return c, true
+}
+
+// peekByte returns the next byte from the input without consuming it,
+// returning false at EOF.
+func (t *Tokenizer) peekByte() (byte, bool) {
+	c, err := t.reader.Peek(1)
+	if err != nil {
+		return 0, false
+	}
+	return c[0], true
+}
+
+// syntaxError returns a SyntaxError describing a problem with the most
+// recently read token.
+func (t *Tokenizer) syntaxError(token Token, msg string) *SyntaxError {
+	// Include a little of the input following the token for context.
+	ahead, _ := t.reader.Peek(snippetContext)
+	if i := bytes.IndexByte(ahead, '\n'); i >= 0 {
+		ahead = ahead[:i]
+	}
+
+	snippet := strings.TrimRight(string(t.current)+string(ahead), "\r")
+
+	// Locate the token within the snippet. Tokens spanning multiple lines are
+	// reported from the start of the current line, and tokens at the end of
+	// the input from the end of the trimmed snippet.
+	snippetOffset := 0
+	if token.line == t.line+1 {
+		snippetOffset = token.column - 1 - (t.column - len(t.current))
+		if snippetOffset < 0 {
+			snippetOffset = 0
+		}
+		if snippetOffset > len(snippet) {
+			snippetOffset = len(snippet)
 		}
+	}
+
+	return &SyntaxError{
+		Msg:           msg,
+		Offset:        token.offset,
+		Line:          token.line,
+		Column:        token.column,
+		Token:         token.String(),
+		Snippet:       snippet,
+		SnippetOffset: snippetOffset,
+	}
+}
 
-		// Handle boolean literals.
-		if strings.HasPrefix(t.Buf[t.pos:], "true") {
-			token.tokenType = JSON_TOKEN_TRUE
-			t.pos += len("true")
-		} else if strings.HasPrefix(t.Buf[t.pos:], "false") {
-			token.tokenType = JSON_TOKEN_FALSE
-			t.pos += len("false")
-		} else if strings.HasPrefix(t.Buf[t.pos:], "null") {
-			token.tokenType = JSON_TOKEN_NULL
-			t.pos += len("null")
+// expectLiteral consumes the remainder of a literal whose first character has
+// already been read.
+func (t *Tokenizer) expectLiteral(token Token, literal string) error {
+	for i := 1; i < len(literal); i++ {
+		c, ok := t.readByte()
+		if !ok || c != literal[i] {
+			err := t.syntaxError(token, fmt.Sprintf("invalid literal, expected %s", literal))
+			err.Token = literal[:i]
+			if ok {
+				err.Token += string(c)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// readString consumes the remainder of a string whose opening quote has
+// already been read, decoding any escape sequences.
+//
+// RFC 8259 - Section 7.
+func (t *Tokenizer) readString(token Token) (string, error) {
+	var value strings.Builder
+	for {
+		c, ok := t.readByte()
+		if !ok {
+			token.string = value.String()
+			return value.String(), t.syntaxError(token, "unterminated string")
 		}
 
-		if token.tokenType != JSON_TOKEN_NONE {
-			t.Tokens = append(t.Tokens, token)
+		switch {
+		case c == '"':
+			return value.String(), nil
+		case c < 0x20:
+			err := t.syntaxError(token, fmt.Sprintf("invalid control character %q in string", c))
+			err.Token = string(c)
+			return value.String(), err
+		case c != '\\':
+			value.WriteByte(c)
 			continue
 		}
 
-		// Consume numeric literals.
-		if (c >= '0' && c <= '9') || c == '-' {
-			start := t.pos
-			for {
-				t.pos++
-				c = t.Buf[t.pos]
-				if c >= '0' && c <= '9' {
-					continue
-				} else if c == '.' || c == 'e' || c == 'E' || c == '+' || c == '-' {
+		c, ok = t.readByte()
+		switch {
+		case !ok:
+			token.string = value.String()
+			return value.String(), t.syntaxError(token, "unterminated string")
+		case c == '"' || c == '\\' || c == '/':
+			value.WriteByte(c)
+		case c == 'b':
+			value.WriteByte('\b')
+		case c == 'f':
+			value.WriteByte('\f')
+		case c == 'n':
+			value.WriteByte('\n')
+		case c == 'r':
+			value.WriteByte('\r')
+		case c == 't':
+			value.WriteByte('\t')
+		case c == 'u':
+			r, err := t.readHex(token)
+			if err != nil {
+				return value.String(), err
+			}
+
+			// Characters outside the Basic Multilingual Plane are encoded as
+			// a UTF-16 surrogate pair of consecutive escapes.
+			if utf16.IsSurrogate(r) {
+				if next, _ := t.reader.Peek(2); string(next) != "\\u" {
+					value.WriteRune(utf8.RuneError)
 					continue
 				}
-				break
+				t.readByte()
+				t.readByte()
+
+				r2, err := t.readHex(token)
+				if err != nil {
+					return value.String(), err
+				}
+				if r = utf16.DecodeRune(r, r2); r == utf8.RuneError && utf16.IsSurrogate(r2) {
+					r2 = utf8.RuneError
+				}
+				if r == utf8.RuneError {
+					value.WriteRune(r)
+					r = r2
+				}
 			}
-			value, _ := strconv.ParseFloat(t.Buf[start:t.pos], 64)
-			token.tokenType = JSON_TOKEN_NUMBER
-			token.number = value
-			t.Tokens = append(t.Tokens, token)
-			continue
+			value.WriteRune(r)
+		default:
+			err := t.syntaxError(token, fmt.Sprintf("invalid escape sequence \\%c", c))
+			err.Token = "\\" + string(c)
+			return value.String(), err
 		}
+	}
+}
 
-		// Must be consuming a string.
-		if c == '"' {
-			t.pos++
-			start := t.pos
-			cprev := uint8(0)
-			for t.pos < len(t.Buf) {
-				if t.Buf[t.pos] == '"' && cprev != '\\' {
-					break
-				}
-				cprev = t.Buf[t.pos]
-				t.pos++
+// readHex consumes the four hexadecimal digits of a \u escape sequence.
+func (t *Tokenizer) readHex(token Token) (rune, error) {
+	var digits [4]byte
+	for i := 0; i < len(digits); i++ {
+		c, ok := t.readByte()
+		if !ok {
+			return 0, t.syntaxError(token, "unterminated string")
+		}
+		digits[i] = c
+	}
+
+	r, err := strconv.ParseUint(string(digits[:]), 16, 32)
+	if err != nil {
+		syntaxError := t.syntaxError(token, fmt.Sprintf("invalid escape sequence \\u%s", digits))
+		syntaxError.Token = "\\u" + string(digits[:])
+		return 0, syntaxError
+	}
+	return rune(r), nil
+}
+
+// isNumber returns true if literal is a number as defined by RFC 8259 -
+// Section 6.
+func isNumber(literal string) bool {
+	digits := func(i int) int {
+		for i < len(literal) && literal[i] >= '0' && literal[i] <= '9' {
+			i++
+		}
+		return i
+	}
+
+	i := 0
+	if i < len(literal) && literal[i] == '-' {
+		i++
+	}
+
+	// Integer part, without leading zeros.
+	switch {
+	case i < len(literal) && literal[i] == '0':
+		i++
+	case i < len(literal) && literal[i] >= '1' && literal[i] <= '9':
+		i = digits(i)
+	default:
+		return false
+	}
+
+	// Optional fraction.
+	if i < len(literal) && literal[i] == '.' {
+		j := digits(i + 1)
+		if j == i+1 {
+			return false
+		}
+		i = j
+	}
+
+	// Optional exponent.
+	if i < len(literal) && (literal[i] == 'e' || literal[i] == 'E') {
+		i++
+		if i < len(literal) && (literal[i] == '+' || literal[i] == '-') {
+			i++
+		}
+		j := digits(i)
+		if j == i {
+			return false
+		}
+		i = j
+	}
+	return i == len(literal)
+}
+
+// Next returns the next token from the input, or a token of type
+// JSON_TOKEN_NONE once the input is exhausted.
+func (t *Tokenizer) Next() (Token, error) {
+	if t.reader == nil {
+		t.reader = bufio.NewReader(strings.NewReader(t.Buf[t.pos:]))
+	}
+
+	// Skip whitespace.
+	for {
+		c, ok := t.peekByte()
+		if !ok || (c != 0x20 && c != 0x09 && c != 0x0A && c != 0x0D) {
+			break
+		}
+		t.readByte()
+	}
+
+	token := Token{offset: t.pos, line: t.line + 1, column: t.column + 1}
+	c, ok := t.readByte()
+	if !ok {
+		return token, nil
+	}
+
+	// Handle structural characters.
+	switch c {
+	case '[':
+		token.tokenType = JSON_TOKEN_LEFT_SQUARE_BRACKET
+	case '{':
+		token.tokenType = JSON_TOKEN_LEFT_CURLY_BRACKET
+	case ']':
+		token.tokenType = JSON_TOKEN_RIGHT_SQUARE_BRACKET
+	case '}':
+		token.tokenType = JSON_TOKEN_RIGHT_CURLY_BRACKET
+	case ':':
+		token.tokenType = JSON_TOKEN_COLON
+	case ',':
+		token.tokenType = JSON_TOKEN_COMMA
+	}
+
+	if token.tokenType != JSON_TOKEN_NONE {
+		return token, nil
+	}
+
+	// Handle boolean literals.
+	switch c {
+	case 't':
+		token.tokenType = JSON_TOKEN_TRUE
+		return token, t.expectLiteral(token, "true")
+	case 'f':
+		token.tokenType = JSON_TOKEN_FALSE
+		return token, t.expectLiteral(token, "false")
+	case 'n':
+		token.tokenType = JSON_TOKEN_NULL
+		return token, t.expectLiteral(token, "null")
+	}
+
+	// Consume numeric literals.
+	if (c >= '0' && c <= '9') || c == '-' {
+		var literal strings.Builder
+		literal.WriteByte(c)
+		for {
+			c, ok := t.peekByte()
+			if !ok {
+				break
 			}
-			token.tokenType = JSON_TOKEN_STRING
-			token.string = t.Buf[start:t.pos]
-			t.Tokens = append(t.Tokens, token)
-			t.pos++
-			continue
+			if (c >= '0' && c <= '9') || c == '.' || c == 'e' || c == 'E' || c == '+' || c == '-' {
+				literal.WriteByte(c)
+				t.readByte()
+				continue
+			}
+			break
 		}
 
-		panic("this should never happen\n")
+		// Numbers too large for a float64 are still valid, and are printed
+		// from their literal text.
+		token.tokenType = JSON_TOKEN_NUMBER
+		value, err := strconv.ParseFloat(literal.String(), 64)
+		if !isNumber(literal.String()) || (err != nil && !errors.Is(err, strconv.ErrRange)) {
+			err := t.syntaxError(token, fmt.Sprintf("invalid number %s", literal.String()))
+			err.Token = literal.String()
+			return token, err
+		}
+		token.string = literal.String()
+		token.number = value
+		return token, nil
 	}
+
+	// Must be consuming a string.
+	if c == '"' {
+		token.tokenType = JSON_TOKEN_STRING
+		value, err := t.readString(token)
+		token.string = value
+		return token, err
+	}
+
+	err := t.syntaxError(token, fmt.Sprintf("invalid character %q", c))
+	err.Token = string(c)
+	return token, err
 }
@@ -1,6 +1,9 @@
 package json
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 type TestCase struct {
 	json   string
@@ -173,10 +176,134 @@ func TestTokenize(t *testing.T) {
 			pos:    0,
 			Tokens: nil,
 		}
-		tokenizer.Tokenize()
+		if err := tokenizer.Tokenize(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
 
 		if len(tokenizer.Tokens) != len(testCase[i].tokens) {
 			t.Fatalf("unexpected number of tokens")
 		}
 	}
 }
+
+func TestTokenize_Reader(t *testing.T) {
+	for i := 0; i < len(testCase); i++ {
+		tokenizer := NewTokenizer(strings.NewReader(testCase[i].json))
+
+		var tokens []TokenType
+		for {
+			token, err := tokenizer.Next()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if token.tokenType == JSON_TOKEN_NONE {
+				break
+			}
+			tokens = append(tokens, token.tokenType)
+		}
+
+		if len(tokens) != len(testCase[i].tokens) {
+			t.Fatalf("unexpected number of tokens")
+		}
+		for j := 0; j < len(tokens); j++ {
+			if tokens[j] != testCase[i].tokens[j] {
+				t.Fatalf("unexpected token")
+			}
+		}
+	}
+}
+
+func TestTokenize_NumberAtEndOfInput(t *testing.T) {
+	tokenizer := NewTokenizer(strings.NewReader("42"))
+
+	token, err := tokenizer.Next()
+	if err != nil || token.tokenType != JSON_TOKEN_NUMBER || token.number != 42 {
+		t.Fatalf("unexpected token")
+	}
+
+	if token, _ := tokenizer.Next(); token.tokenType != JSON_TOKEN_NONE {
+		t.Fatalf("expected end of input")
+	}
+}
+
+func TestTokenize_Numbers(t *testing.T) {
+	valid := []string{"0", "-0", "42", "-7", "1.50", "0.001", "1e3", "1E+3", "2.5e-10",
+		"1234567890123456789", "12345678901234567890123", "1e400"}
+	for _, literal := range valid {
+		token, err := NewTokenizer(strings.NewReader(literal)).Next()
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %s", literal, err)
+		}
+		if token.tokenType != JSON_TOKEN_NUMBER || token.String() != literal {
+			t.Fatalf("expected %s, got %s", literal, token)
+		}
+	}
+
+	invalid := []string{"01", "-", "1.", "1.e5", "1e", "1e+", "--1", "1-2", "1.2.3"}
+	for _, literal := range invalid {
+		if _, err := NewTokenizer(strings.NewReader(literal)).Next(); err == nil {
+			t.Fatalf("expected error for %s", literal)
+		}
+	}
+}
+
+func TestTokenize_Strings(t *testing.T) {
+	type TestCase struct {
+		json     string
+		expected string
+	}
+
+	cases := []TestCase{
+		{`"hello, world"`, "hello, world"},
+		{`"\"quoted\""`, `"quoted"`},
+		{`"back\\slash"`, `back\slash`},
+		{`"\/\b\f\n\r\t"`, "/\b\f\n\r\t"},
+		{`"caf\u00e9"`, "café"},
+		{`"\u00E9\u4e16"`, "é世"},
+		{`"\ud83d\ude00"`, "😀"},
+		{`"\ud83d"`, "\ufffd"},
+		{`"\ude00x"`, "\ufffdx"},
+		{`"\ud83d\u0041"`, "\ufffdA"},
+		{`"δ"`, "δ"},
+	}
+
+	for _, _case := range cases {
+		tokenizer := NewTokenizer(strings.NewReader(_case.json))
+
+		token, err := tokenizer.Next()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if token.tokenType != JSON_TOKEN_STRING || token.string != _case.expected {
+			t.Fatalf("expected %q, got %q", _case.expected, token.string)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	type TestCase struct {
+		value    string
+		expected string
+	}
+
+	cases := []TestCase{
+		{"hello, world", `"hello, world"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`C:\temp`, `"C:\\temp"`},
+		{"a\nb\tc", `"a\nb\tc"`},
+		{"\x00\x1f", `"\u0000\u001f"`},
+		{"café 😀", `"café 😀"`},
+	}
+
+	for _, _case := range cases {
+		if result := quote(_case.value); result != _case.expected {
+			t.Fatalf("expected %s, got %s", _case.expected, result)
+		}
+
+		// Quoted strings should decode back to the original value.
+		token, err := NewTokenizer(strings.NewReader(quote(_case.value))).Next()
+		if err != nil || token.string != _case.value {
+			t.Fatalf("failed to round trip %q", _case.value)
+		}
+	}
+}
@@ -8,6 +8,10 @@ const (
 	Column IdentifierKind = iota
 	Table
 	None
+	Alias       // Aliases of tables, table-valued functions and sub-queries.
+	CommonTable // References to common tables defined by WITH clauses.
+	Source      // Tables, table-valued functions and sub-queries read by a statement.
+	Function    // Aliases of table-valued functions.
 )
 
 type JoinType int
@@ -20,7 +24,18 @@ const (
 	RightOuter
 	Full
 	FullOuter
-	Union
+	Cross
+)
+
+// CompoundOperator combines the results of the SELECTs of a compound
+// statement.
+type CompoundOperator int
+
+const (
+	Union CompoundOperator = iota
+	UnionAll
+	Intersect
+	Except
 )
 
 // Empty table expression interface.
@@ -28,8 +43,9 @@ type TableExpr interface {
 	isTableExpr()
 }
 
-func (t *SelectStmt) isTableExpr()     {}
-func (t *IdentifierExpr) isTableExpr() {}
+func (t *SelectStmt) isTableExpr()       {}
+func (t *IdentifierExpr) isTableExpr()   {}
+func (t *FunctionCallExpr) isTableExpr() {}
 
 // Empty expression interface.
 type Expr interface {
@@ -83,6 +99,8 @@ type (
 		function string
 		distinct bool
 		operands []Expr
+		filter   Expr        // FILTER (WHERE filter), if any.
+		over     *WindowDefn // Window of a window function, if any.
 	}
 
 	CastExpr struct {
@@ -121,9 +139,14 @@ type (
 		right   Expr
 	}
 
+	// InExpr tests expr against either a list of expressions, the rows of a
+	// sub-query or the rows of a table.
 	InExpr struct {
-		inverse bool
-		expr    Expr
+		inverse    bool
+		expr       Expr
+		list       []Expr
+		selectStmt *SelectStmt
+		table      *IdentifierExpr
 	}
 
 	ExistsExpr struct {
@@ -208,6 +231,12 @@ func eqExpr(a, b Expr) bool {
 				return false
 			}
 		}
+		if !eqExpr(a.(*FunctionCallExpr).filter, b.(*FunctionCallExpr).filter) {
+			return false
+		}
+		if !eqWindowDefn(a.(*FunctionCallExpr).over, b.(*FunctionCallExpr).over) {
+			return false
+		}
 		return true
 	case *CastExpr:
 		if _, ok := b.(*CastExpr); !ok {
@@ -293,10 +322,31 @@ func eqExpr(a, b Expr) bool {
 			return false
 		}
 
-		if a.(*InExpr).inverse != b.(*InExpr).inverse {
+		inA, inB := a.(*InExpr), b.(*InExpr)
+		if inA.inverse != inB.inverse || !eqExpr(inA.expr, inB.expr) {
+			return false
+		}
+
+		if len(inA.list) != len(inB.list) {
+			return false
+		}
+		for i := 0; i < len(inA.list); i++ {
+			if !eqExpr(inA.list[i], inB.list[i]) {
+				return false
+			}
+		}
+
+		if (inA.selectStmt == nil) != (inB.selectStmt == nil) {
 			return false
 		}
-		return eqExpr(a.(*InExpr).expr, b.(*InExpr).expr)
+		if inA.selectStmt != nil && !eqSelectStmt(inA.selectStmt, inB.selectStmt) {
+			return false
+		}
+
+		if inA.table == nil || inB.table == nil {
+			return inA.table == inB.table
+		}
+		return eqExpr(inA.table, inB.table)
 	case *ExistsExpr:
 		if _, ok := b.(*ExistsExpr); !ok {
 			return false
@@ -356,6 +406,7 @@ func eqResultColumn(a, b ResultColumn) bool {
 
 type JoinedTable struct {
 	source TableExpr
+	alias  string // Alias of a table-valued function or sub-query.
 	joins  []Join
 }
 
@@ -376,6 +427,11 @@ func eqTableExpr(a, b TableExpr) bool {
 			return false
 		}
 		return eqExpr(a.(*IdentifierExpr), b.(*IdentifierExpr))
+	case *FunctionCallExpr:
+		if _, ok := b.(*FunctionCallExpr); !ok {
+			return false
+		}
+		return eqExpr(a.(*FunctionCallExpr), b.(*FunctionCallExpr))
 
 	default:
 		return false
@@ -384,7 +440,7 @@ func eqTableExpr(a, b TableExpr) bool {
 
 // eqJoinedTable checks two JoinedTables for equality.
 func eqJoinedTable(a, b JoinedTable) bool {
-	if !eqTableExpr(a.source, b.source) {
+	if !eqTableExpr(a.source, b.source) || a.alias != b.alias {
 		return false
 	}
 
@@ -484,7 +540,86 @@ func eqLimitExpr(a, b LimitExpr) bool {
 	return eqExpr(a.skip, b.skip)
 }
 
+// WindowDefn defines the window of a window function, either in an OVER
+// clause or in the WINDOW clause of a statement.
+type WindowDefn struct {
+	name        string // Name of a window of a WINDOW clause, or of the window of OVER name.
+	base        string // Name of the window extended by OVER (base ...), if any.
+	partitionBy []Expr
+	orderBy     []OrderByExpr
+	frame       *FrameSpec
+}
+
+// eqWindowDefn checks two WindowDefns for equality.
+func eqWindowDefn(a, b *WindowDefn) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	if a.name != b.name || a.base != b.base {
+		return false
+	}
+
+	if len(a.partitionBy) != len(b.partitionBy) {
+		return false
+	}
+	for i := 0; i < len(a.partitionBy); i++ {
+		if !eqExpr(a.partitionBy[i], b.partitionBy[i]) {
+			return false
+		}
+	}
+
+	if len(a.orderBy) != len(b.orderBy) {
+		return false
+	}
+	for i := 0; i < len(a.orderBy); i++ {
+		if !eqOrderByExpr(a.orderBy[i], b.orderBy[i]) {
+			return false
+		}
+	}
+
+	return eqFrameSpec(a.frame, b.frame)
+}
+
+// FrameSpec is the frame of a window, being the rows of its partition a
+// window function is evaluated over.
+type FrameSpec struct {
+	unit    Token // RANGE | ROWS | GROUPS
+	start   FrameBound
+	end     *FrameBound // Set for BETWEEN start AND end.
+	exclude Token       // NO (OTHERS) | CURRENT (ROW) | GROUP | TIES, if any.
+}
+
+// FrameBound is the start or end of the frame of a window.
+type FrameBound struct {
+	unbounded bool
+	expr      Expr  // Offset of the bound, if any.
+	direction Token // PRECEDING | FOLLOWING | CURRENT (ROW)
+}
+
+// eqFrameSpec checks two FrameSpecs for equality.
+func eqFrameSpec(a, b *FrameSpec) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	if a.unit != b.unit || a.exclude != b.exclude || !eqFrameBound(&a.start, &b.start) {
+		return false
+	}
+	return eqFrameBound(a.end, b.end)
+}
+
+// eqFrameBound checks two FrameBounds for equality.
+func eqFrameBound(a, b *FrameBound) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.unbounded == b.unbounded && a.direction == b.direction && eqExpr(a.expr, b.expr)
+}
+
 type SelectStmt struct {
+	with *WithClause
+
 	isAll      bool
 	isDistinct bool
 
@@ -493,8 +628,98 @@ type SelectStmt struct {
 	whereClause   Expr
 	groupByClause []Expr
 	havingClause  Expr
+	windowClause  []WindowDefn
 	orderByClause []OrderByExpr
 	limitClause   LimitExpr
+
+	// SELECTs combined with this one in a compound statement, whose ORDER BY
+	// and LIMIT clauses apply to the whole compound.
+	compound []CompoundSelect
+
+	// Path column references, -> and ->> operators and result columns to be
+	// named after their text in the statement, set for the outermost
+	// statement only.
+	references []reference
+	arrows     []arrow
+	unnamed    []span
+}
+
+// WithClause defines the common tables of a statement.
+type WithClause struct {
+	recursive bool
+	tables    []CommonTableExpr
+}
+
+// CommonTableExpr is a named sub-query defined by a WITH clause, which can be
+// referenced as a table by the rest of the statement.
+type CommonTableExpr struct {
+	name    string
+	columns []string
+	stmt    *SelectStmt
+}
+
+// eqWithClause checks two WithClauses for equality.
+func eqWithClause(a, b *WithClause) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	if a.recursive != b.recursive || len(a.tables) != len(b.tables) {
+		return false
+	}
+	for i := 0; i < len(a.tables); i++ {
+		if a.tables[i].name != b.tables[i].name || len(a.tables[i].columns) != len(b.tables[i].columns) {
+			return false
+		}
+		for j := 0; j < len(a.tables[i].columns); j++ {
+			if a.tables[i].columns[j] != b.tables[i].columns[j] {
+				return false
+			}
+		}
+		if !eqSelectStmt(a.tables[i].stmt, b.tables[i].stmt) {
+			return false
+		}
+	}
+	return true
+}
+
+// CompoundSelect is a SELECT of a compound statement, and the operator
+// combining its results with those of the SELECTs before it.
+type CompoundSelect struct {
+	operator CompoundOperator
+	stmt     *SelectStmt
+}
+
+// eqCompoundSelect checks two CompoundSelects for equality.
+func eqCompoundSelect(a, b CompoundSelect) bool {
+	if a.operator != b.operator {
+		return false
+	}
+	return eqSelectStmt(a.stmt, b.stmt)
+}
+
+// reference is the location of a column reference with a path, such as
+// about.metric, in the text of a statement.
+type reference struct {
+	start int    // Offset of the reference.
+	first int    // End offset of the first element of the path.
+	end   int    // End offset of the reference.
+	value string // Value of the IdentifierExpr.
+}
+
+// arrow is the location of a -> or ->> operator, and of its operands, in the
+// text of a statement.
+type arrow struct {
+	start    int   // Offset of the left operand.
+	operator int   // Offset of the operator.
+	end      int   // End offset of the right operand.
+	token    Token // ARROW or ARROW2.
+}
+
+// span is the location of part of the text of a statement.
+type span struct {
+	start int
+	end   int
 }
 
 // eqSelectStmt checks two SelectStmts for equality.
@@ -507,6 +732,10 @@ func eqSelectStmt(a, b *SelectStmt) bool {
 		return false
 	}
 
+	if !eqWithClause(a.with, b.with) {
+		return false
+	}
+
 	if a.isAll != b.isAll {
 		return false
 	}
@@ -550,6 +779,15 @@ func eqSelectStmt(a, b *SelectStmt) bool {
 		return false
 	}
 
+	if len(a.windowClause) != len(b.windowClause) {
+		return false
+	}
+	for i := 0; i < len(a.windowClause); i++ {
+		if !eqWindowDefn(&a.windowClause[i], &b.windowClause[i]) {
+			return false
+		}
+	}
+
 	if len(a.orderByClause) != len(b.orderByClause) {
 		return false
 	}
@@ -559,31 +797,34 @@ func eqSelectStmt(a, b *SelectStmt) bool {
 		}
 	}
 
+	if len(a.compound) != len(b.compound) {
+		return false
+	}
+	for i := 0; i < len(a.compound); i++ {
+		if !eqCompoundSelect(a.compound[i], b.compound[i]) {
+			return false
+		}
+	}
+
 	return eqLimitExpr(a.limitClause, b.limitClause)
 }
 
 // extractIdentifierFromExpression returns all identifiers present in an expression
-func extractIdentifierFromExpression(expr Expr, kind IdentifierKind, idents map[string]bool) {
+func extractIdentifierFromExpression(expr Expr, kind IdentifierKind, idents map[string]int) {
 	switch expr.(type) {
 	case *StarExpr:
 		if kind != Table {
-			if found := idents["*"]; !found {
-				idents["*"] = true
-			}
+			idents["*"]++
 		}
 	case *LiteralExpr:
 		value := expr.(*LiteralExpr)
 		if value.kind == kind {
-			if found := idents[value.value]; !found {
-				idents[value.value] = true
-			}
+			idents[value.value]++
 		}
 	case *IdentifierExpr:
 		value := expr.(*IdentifierExpr)
 		if value.kind == kind {
-			if found := idents[expr.(*IdentifierExpr).value]; !found {
-				idents[expr.(*IdentifierExpr).value] = true
-			}
+			idents[value.value]++
 		}
 	case *UnaryExpr:
 		extractIdentifierFromExpression(expr.(*UnaryExpr).expr, kind, idents)
@@ -591,10 +832,16 @@ func extractIdentifierFromExpression(expr Expr, kind IdentifierKind, idents map[
 		extractIdentifierFromExpression(expr.(*BinaryExpr).left, kind, idents)
 		extractIdentifierFromExpression(expr.(*BinaryExpr).right, kind, idents)
 	case *FunctionCallExpr:
+		// The '*' in COUNT(*) does not refer to any columns.
 		funCallExpr := expr.(*FunctionCallExpr)
 		for i := 0; i < len(funCallExpr.operands); i++ {
+			if _, ok := funCallExpr.operands[i].(*StarExpr); ok {
+				continue
+			}
 			extractIdentifierFromExpression(funCallExpr.operands[i], kind, idents)
 		}
+		extractIdentifierFromExpression(funCallExpr.filter, kind, idents)
+		extractIdentifiersFromWindow(funCallExpr.over, kind, idents)
 	case *CastExpr:
 		extractIdentifierFromExpression(expr.(*CastExpr).expr, kind, idents)
 	case *CollateExpr:
@@ -613,7 +860,20 @@ func extractIdentifierFromExpression(expr Expr, kind IdentifierKind, idents map[
 		extractIdentifierFromExpression(expr.(*BetweenExpr).left, kind, idents)
 		extractIdentifierFromExpression(expr.(*BetweenExpr).right, kind, idents)
 	case *InExpr:
-		extractIdentifierFromExpression(expr.(*InExpr).expr, kind, idents)
+		inExpr := expr.(*InExpr)
+		extractIdentifierFromExpression(inExpr.expr, kind, idents)
+		for i := 0; i < len(inExpr.list); i++ {
+			extractIdentifierFromExpression(inExpr.list[i], kind, idents)
+		}
+		if inExpr.selectStmt != nil {
+			extractIdentifiersImpl(inExpr.selectStmt, kind, idents)
+		}
+		if inExpr.table != nil {
+			extractIdentifierFromExpression(inExpr.table, kind, idents)
+			if kind == Source {
+				idents[inExpr.table.value]++
+			}
+		}
 	case *ExistsExpr:
 		extractIdentifiersImpl(expr.(*ExistsExpr).selectStmt, kind, idents)
 	case *CaseExpr:
@@ -632,7 +892,56 @@ func extractIdentifierFromExpression(expr Expr, kind IdentifierKind, idents map[
 	}
 }
 
-func extractIndentifiersFromJoinedTable(joinedTable *JoinedTable, kind IdentifierKind, idents map[string]bool) {
+func extractIdentifiersFromWindow(window *WindowDefn, kind IdentifierKind, idents map[string]int) {
+	if window == nil {
+		return
+	}
+
+	for i := 0; i < len(window.partitionBy); i++ {
+		extractIdentifierFromExpression(window.partitionBy[i], kind, idents)
+	}
+	for i := 0; i < len(window.orderBy); i++ {
+		extractIdentifierFromExpression(window.orderBy[i].expr, kind, idents)
+	}
+	if window.frame != nil {
+		extractIdentifierFromExpression(window.frame.start.expr, kind, idents)
+		if window.frame.end != nil {
+			extractIdentifierFromExpression(window.frame.end.expr, kind, idents)
+		}
+	}
+}
+
+func extractIndentifiersFromJoinedTable(joinedTable *JoinedTable, kind IdentifierKind, idents map[string]int) {
+	if kind == Alias {
+		if table, ok := joinedTable.source.(*IdentifierExpr); ok && table.alias != "" {
+			idents[table.alias]++
+		}
+		if joinedTable.alias != "" {
+			idents[joinedTable.alias]++
+		}
+	}
+
+	if kind == Function {
+		if _, ok := joinedTable.source.(*FunctionCallExpr); ok && joinedTable.alias != "" {
+			idents[joinedTable.alias]++
+		}
+	}
+
+	// Sub-queries are sources in their own right only where they read no
+	// other sources, such as sub-queries of literal values.
+	if kind == Source {
+		switch source := joinedTable.source.(type) {
+		case *IdentifierExpr:
+			idents[source.value]++
+		case *FunctionCallExpr:
+			idents[source.function]++
+		case *SelectStmt:
+			if CountIdentifiers(source, Source) == 0 {
+				idents[""]++
+			}
+		}
+	}
+
 	switch joinedTable.source.(type) {
 	case Expr:
 		extractIdentifierFromExpression(joinedTable.source.(Expr), kind, idents)
@@ -653,7 +962,14 @@ func extractIndentifiersFromJoinedTable(joinedTable *JoinedTable, kind Identifie
 	}
 }
 
-func extractIdentifiersImpl(stmt *SelectStmt, kind IdentifierKind, idents map[string]bool) {
+func extractIdentifiersImpl(stmt *SelectStmt, kind IdentifierKind, idents map[string]int) {
+	// Extract identifiers from common tables.
+	if stmt.with != nil {
+		for i := 0; i < len(stmt.with.tables); i++ {
+			extractIdentifiersImpl(stmt.with.tables[i].stmt, kind, idents)
+		}
+	}
+
 	// Extract identifiers from result columns.
 	for i := 0; i < len(stmt.resultColumn); i++ {
 		extractIdentifierFromExpression(stmt.resultColumn[i].expr, kind, idents)
@@ -675,6 +991,11 @@ func extractIdentifiersImpl(stmt *SelectStmt, kind IdentifierKind, idents map[st
 	// Handle HAVING clause.
 	extractIdentifierFromExpression(stmt.havingClause, kind, idents)
 
+	// Handle WINDOW clause.
+	for i := 0; i < len(stmt.windowClause); i++ {
+		extractIdentifiersFromWindow(&stmt.windowClause[i], kind, idents)
+	}
+
 	// Handle ORDER BY clauses.
 	for i := 0; i < len(stmt.orderByClause); i++ {
 		extractIdentifierFromExpression(stmt.orderByClause[i].expr, kind, idents)
@@ -683,11 +1004,16 @@ func extractIdentifiersImpl(stmt *SelectStmt, kind IdentifierKind, idents map[st
 	// Handle LIMIT clause.
 	extractIdentifierFromExpression(stmt.limitClause.skip, kind, idents)
 	extractIdentifierFromExpression(stmt.limitClause.count, kind, idents)
+
+	// Handle the other SELECTs of a compound statement.
+	for i := 0; i < len(stmt.compound); i++ {
+		extractIdentifiersImpl(stmt.compound[i].stmt, kind, idents)
+	}
 }
 
 // ExtractIdentifiers returns all identifiers from a SELECT statement.
 func ExtractIdentifiers(stmt *SelectStmt, kind IdentifierKind) []string {
-	uniqueIdentifiers := make(map[string]bool, 0)
+	uniqueIdentifiers := make(map[string]int, 0)
 	extractIdentifiersImpl(stmt, kind, uniqueIdentifiers)
 
 	identifiers := make([]string, 0)
@@ -698,3 +1024,16 @@ func ExtractIdentifiers(stmt *SelectStmt, kind IdentifierKind) []string {
 	sort.Strings(identifiers)
 	return identifiers
 }
+
+// CountIdentifiers returns the total number of references to identifiers of a
+// given kind in a SELECT statement, counting repeated references separately.
+func CountIdentifiers(stmt *SelectStmt, kind IdentifierKind) int {
+	identifiers := make(map[string]int, 0)
+	extractIdentifiersImpl(stmt, kind, identifiers)
+
+	count := 0
+	for _, n := range identifiers {
+		count += n
+	}
+	return count
+}
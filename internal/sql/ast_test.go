@@ -28,6 +28,10 @@ func TestExtractIdentifiers_Columns(t *testing.T) {
 			"SELECT a FROM (SELECT b FROM c);",
 			[]string{"a", "b"},
 		},
+		{
+			"SELECT a, 'b', \"c\" FROM t WHERE \"d\".x = \"e\";",
+			[]string{"a", "c", "d.x"},
+		},
 		{
 			"SELECT * FROM a JOIN b ON a.x == b.y JOIN c ON b.y == c.z;",
 			[]string{"*", "a.x", "b.y", "c.z"},
@@ -44,6 +48,18 @@ func TestExtractIdentifiers_Columns(t *testing.T) {
 				"GROUP BY x.customer, x.total;",
 			[]string{"x.id", "x.customer", "x.total", "p.customer", "total", "y.customer", "y.max_total"},
 		},
+		{
+			"SELECT a FROM b UNION SELECT c FROM d WHERE e > 1 ORDER BY f;",
+			[]string{"a", "c", "e", "f"},
+		},
+		{
+			"SELECT sum(a) FILTER (WHERE b > 0) OVER (PARTITION BY c ORDER BY d ROWS e PRECEDING) FROM f WINDOW w AS (ORDER BY g);",
+			[]string{"a", "b", "c", "d", "e", "g"},
+		},
+		{
+			"SELECT a FROM b WHERE c IN (d, e + 1) AND f NOT IN (SELECT g FROM h WHERE i > 0);",
+			[]string{"a", "c", "d", "e", "f", "g", "i"},
+		},
 	}
 
 	for _, test := range cases {
@@ -112,3 +128,72 @@ func TestExtractIdentifiers_Tables(t *testing.T) {
 			}
 		}*/
 }
+
+func TestCountIdentifiers_Tables(t *testing.T) {
+	// Arrange.
+	type TestCase struct {
+		statement string
+		expected  int
+	}
+	cases := []TestCase{
+		{"SELECT 1;", 0},
+		{"SELECT a FROM [];", 1},
+		{"SELECT a FROM [] WHERE a > (SELECT MIN(a) FROM []);", 2},
+		{"SELECT a.id, b.value FROM a JOIN b ON a.id == b.value;", 2},
+		{"SELECT a FROM [] UNION ALL SELECT a FROM [] EXCEPT SELECT b FROM c;", 3},
+		{"WITH RECURSIVE t AS (SELECT a FROM [] UNION SELECT a FROM t) SELECT a FROM t;", 1},
+		{"SELECT a FROM [] WHERE a IN (SELECT b FROM c) OR a NOT IN d;", 3},
+	}
+
+	for _, test := range cases {
+		// Act.
+		stmt := parseStatement(test.statement)
+		count := CountIdentifiers(&stmt, Table)
+
+		// Assert.
+		if count != test.expected {
+			t.Errorf("unexpected number of table references: got %d, expected %d", count, test.expected)
+		}
+	}
+}
+
+func TestExtractIdentifiers_Functions(t *testing.T) {
+	// Arrange.
+	stmt := parseStatement("SELECT i.sku FROM [] AS o, each(o.items) AS i, (SELECT 1) AS s, unnest('[1]') u;")
+
+	// Act.
+	aliases := ExtractIdentifiers(&stmt, Function)
+
+	// Assert.
+	if len(aliases) != 2 || aliases[0] != "i" || aliases[1] != "u" {
+		t.Errorf("unexpected aliases of functions: %v", aliases)
+	}
+}
+
+func TestCountIdentifiers_Sources(t *testing.T) {
+	// Arrange.
+	type TestCase struct {
+		statement string
+		expected  int
+	}
+	cases := []TestCase{
+		{"SELECT 1;", 0},
+		{"SELECT a FROM [];", 1},
+		{"SELECT a FROM (SELECT a FROM []);", 1},
+		{"SELECT a, e.value FROM each('[1,2]') AS e, [];", 2},
+		{"SELECT a FROM (SELECT 1 AS z UNION ALL SELECT 3) AS v JOIN [] ON v.z = a;", 2},
+		{"SELECT (SELECT b FROM c) FROM [];", 2},
+		{"SELECT a FROM [] WHERE a IN b;", 2},
+	}
+
+	for _, test := range cases {
+		// Act.
+		stmt := parseStatement(test.statement)
+		count := CountIdentifiers(&stmt, Source)
+
+		// Assert.
+		if count != test.expected {
+			t.Errorf("unexpected number of sources: got %d, expected %d", count, test.expected)
+		}
+	}
+}
@@ -2,12 +2,13 @@ package sql
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 )
 
 func precedence(token Token) int {
 	switch token {
-	case CONCAT:
+	case CONCAT, ARROW, ARROW2:
 		return 9
 	case STAR, SLASH, REM:
 		return 8
@@ -30,11 +31,63 @@ func precedence(token Token) int {
 	}
 }
 
+// SyntaxError describes a malformed SQL statement.
+type SyntaxError struct {
+	Msg      string
+	Position          // Position of the offending token.
+	Token    string // The offending token.
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// describe returns a description of a token for use in error messages.
+func describe(token Token, value string) string {
+	switch token {
+	case EOF:
+		return "end of input"
+	case IDENTIFIER, NUMERIC_LITERAL, INVALID:
+		return fmt.Sprintf("%q", value)
+	case STRING_LITERAL:
+		return "'" + value + "'"
+	default:
+		return token.String()
+	}
+}
+
+// isFallback returns true for the keywords that, as in SQLite, can also be used
+// as identifiers where no keyword is expected, such as the key column of each.
+func isFallback(token Token) bool {
+	switch token {
+	case ABORT, ACTION, AFTER, ALWAYS, ANALYZE, ASC, ATTACH, BEFORE, BEGIN, BY,
+		CASCADE, CONFLICT, CURRENT, DATABASE, DEFERRED, DESC, DETACH, DO, EACH,
+		END, EXCLUDE, EXCLUSIVE, EXPLAIN, FAIL, FIRST, FOLLOWING, FOR, GENERATED,
+		GROUPS, IF, IGNORE, IMMEDIATE, INITIALLY, INSTEAD, KEY, LAST, NO, NULLS,
+		OF, OFFSET, OTHERS, PARTITION, PLAN, PRAGMA, PRECEDING, QUERY, RANGE,
+		RECURSIVE, REINDEX, RELEASE, RENAME, REPLACE, RESTRICT, ROLLBACK, ROW,
+		ROWS, SAVEPOINT, TEMP, TEMPORARY, TIES, TRIGGER, UNBOUNDED, VACUUM, VIEW,
+		VIRTUAL, WITH, WITHOUT:
+		return true
+	default:
+		return false
+	}
+}
+
 // Parser is a type that converts a stream of tokens into an AST.
 type Parser struct {
 	scanner *Scanner
 	token   Token
 	value   string
+	pos     Position
+	end     int // End offset of the previous token.
+
+	references []reference
+	arrows     []arrow
+	unnamed    []span
+
+	// Names of the common tables in scope.
+	commonTables []string
 }
 
 func NewParser(scanner *Scanner) *Parser {
@@ -48,38 +101,206 @@ func (p *Parser) init() {
 }
 
 func (p *Parser) next() {
+	p.end = p.scanner.pos.Offset
 	p.token, p.value = p.scanner.ScanToken()
+	p.pos = p.scanner.Position()
+}
+
+// errorf aborts parsing with a SyntaxError describing the current token. The
+// error is recovered and returned by Parse.
+func (p *Parser) errorf(format string, args ...interface{}) {
+	panic(&SyntaxError{
+		Msg:      fmt.Sprintf(format, args...),
+		Position: p.pos,
+		Token:    describe(p.token, p.value),
+	})
+}
+
+// expected aborts parsing with a SyntaxError describing what was expected in
+// place of the current token.
+func (p *Parser) expected(what string) {
+	p.errorf("expected %s, got %s", what, describe(p.token, p.value))
+}
+
+// isQuotedIdentifier returns true if the current token is a double quoted
+// string, which SQLite takes to be an identifier where one is expected.
+func (p *Parser) isQuotedIdentifier() bool {
+	return p.token == STRING_LITERAL && p.scanner.input[p.pos.Offset] == '"'
+}
+
+// consumeIdentifier consumes an identifier, a double quoted string or a keyword
+// that can be used as one, returning its value.
+func (p *Parser) consumeIdentifier() string {
+	if p.token != IDENTIFIER && !p.isQuotedIdentifier() && !isFallback(p.token) {
+		p.expected(IDENTIFIER.String())
+	}
+	value := p.value
+	p.next()
+	return value
 }
 
 func (p *Parser) assertAndConsumeToken(expected Token) {
 	if p.token != expected {
-		panic(fmt.Sprintf("unexpected token: got %s, expected %s", p.token, expected))
+		p.expected(expected.String())
 	}
 	p.next()
 }
 
-// stmt ::= select-stmt
-func (p *Parser) Parse() SelectStmt {
+// stmt ::= select-stmt [ ; ]
+func (p *Parser) Parse() (stmt SelectStmt, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			syntaxError, ok := r.(*SyntaxError)
+			if !ok {
+				panic(r)
+			}
+			err = syntaxError
+		}
+	}()
+
 	p.init()
 
-	if p.token != SELECT {
-		panic("only SELECT statements are supported")
+	if p.token != SELECT && p.token != WITH {
+		p.errorf("only SELECT statements are supported, got %s", describe(p.token, p.value))
 	}
-	p.next()
-	return p.parseSelectStmt()
+	stmt = p.parseSelectStmt()
+	stmt.references = p.references
+	stmt.arrows = p.arrows
+	stmt.unnamed = p.unnamed
+
+	if p.token == SEMI {
+		p.next()
+	}
+	if p.token != EOF {
+		p.expected("end of statement")
+	}
+	return stmt, nil
 }
 
-// select-stmt ::= SELECT [ DISTINCT | ALL ]
-// 			  	   result-column [, result-column ]*
-//				   [ FROM table-list ]
-//          	   [ WHERE expr ]
-//      		   [ GROUP BY expr [, expr ]* ]
-//      		   [ HAVING expr ]
-//      		   [ ORDER BY ordering-term [, ordering-term ]* ]
-//      		   [ LIMIT expr [ ( OFFSET | , ) expr ]
+// select-stmt ::= [ with-clause ] select-core [ compound-operator select-core ]*
+//                 [ ORDER BY ordering-term [, ordering-term ]* ]
+//                 [ LIMIT expr [ ( OFFSET | , ) expr ] ]
 //
-// TODO: Add WINDOW support.
+// compound-operator ::= UNION [ ALL ] | INTERSECT | EXCEPT
 func (p *Parser) parseSelectStmt() SelectStmt {
+	// Common tables are in scope for the rest of the statement.
+	scope := len(p.commonTables)
+	var with *WithClause
+	if p.token == WITH {
+		with = p.parseWithClause()
+	}
+
+	p.assertAndConsumeToken(SELECT)
+	stmt := p.parseSelectCore()
+	stmt.with = with
+	for {
+		var operator CompoundOperator
+		switch p.token {
+		case UNION:
+			operator = Union
+			if p.next(); p.token == ALL {
+				operator = UnionAll
+				p.next()
+			}
+		case INTERSECT:
+			operator = Intersect
+			p.next()
+		case EXCEPT:
+			operator = Except
+			p.next()
+		default:
+			stmt = p.parseSelectTail(stmt)
+			p.commonTables = p.commonTables[:scope]
+			return stmt
+		}
+
+		p.assertAndConsumeToken(SELECT)
+		core := p.parseSelectCore()
+		stmt.compound = append(stmt.compound, CompoundSelect{operator: operator, stmt: &core})
+	}
+}
+
+// with-clause ::= WITH [ RECURSIVE ] common-table [, common-table ]*
+// common-table ::= table-name [ ( column-name [, column-name ]* ) ] AS ( select-stmt )
+//
+// As in SQLite, a common table can refer to itself whether or not the clause
+// is RECURSIVE, and to the common tables before it.
+func (p *Parser) parseWithClause() *WithClause {
+	p.assertAndConsumeToken(WITH)
+	with := &WithClause{}
+	if p.token == RECURSIVE {
+		with.recursive = true
+		p.next()
+	}
+
+	for {
+		table := CommonTableExpr{name: p.consumeIdentifier()}
+		if p.token == LP {
+			p.next()
+			table.columns = append(table.columns, p.consumeIdentifier())
+			for p.token == COMMA {
+				p.next()
+				table.columns = append(table.columns, p.consumeIdentifier())
+			}
+			p.assertAndConsumeToken(RP)
+		}
+		p.commonTables = append(p.commonTables, table.name)
+
+		p.assertAndConsumeToken(AS)
+		p.assertAndConsumeToken(LP)
+		stmt := p.parseSelectStmt()
+		table.stmt = &stmt
+		p.assertAndConsumeToken(RP)
+		with.tables = append(with.tables, table)
+
+		if p.token != COMMA {
+			return with
+		}
+		p.next()
+	}
+}
+
+// tableKind returns the kind of a table name, being the name of either a JSON
+// table or a common table in scope.
+func (p *Parser) tableKind(name string) IdentifierKind {
+	for _, table := range p.commonTables {
+		if strings.EqualFold(table, name) {
+			return CommonTable
+		}
+	}
+	return Table
+}
+
+// parseSelectTail parses the ORDER BY and LIMIT clauses of a statement.
+func (p *Parser) parseSelectTail(stmt SelectStmt) SelectStmt {
+	if p.token == ORDER {
+		p.next()
+		p.assertAndConsumeToken(BY)
+		stmt.orderByClause = append(stmt.orderByClause, p.parseOrderingTerm())
+		for p.token == COMMA {
+			p.next()
+			stmt.orderByClause = append(stmt.orderByClause, p.parseOrderingTerm())
+		}
+	}
+	if p.token == LIMIT {
+		p.next()
+		stmt.limitClause.count = p.parseExpr(0)
+		if p.token == OFFSET || p.token == COMMA {
+			p.next()
+			stmt.limitClause.skip = p.parseExpr(0)
+		}
+	}
+	return stmt
+}
+
+// select-core ::= SELECT [ DISTINCT | ALL ]
+//                 result-column [, result-column ]*
+//                 [ FROM table-list ]
+//                 [ WHERE expr ]
+//                 [ GROUP BY expr [, expr ]* ]
+//                 [ HAVING expr ]
+//                 [ WINDOW window-name AS window-defn [, window-name AS window-defn ]* ]
+func (p *Parser) parseSelectCore() SelectStmt {
 	stmt := SelectStmt{}
 	if p.token == DISTINCT || p.token == ALL {
 		stmt.isAll = p.token == ALL
@@ -90,14 +311,15 @@ func (p *Parser) parseSelectStmt() SelectStmt {
 	// parse projection and available clauses
 	stmt.resultColumn = p.parseResultColumn()
 	for {
-		token := p.token
-		p.next()
-		switch token {
+		switch p.token {
 		case FROM:
+			p.next()
 			stmt.fromClause = p.parseTableList()
 		case WHERE:
+			p.next()
 			stmt.whereClause = p.parseExpr(0)
 		case GROUP:
+			p.next()
 			p.assertAndConsumeToken(BY)
 			stmt.groupByClause = append(stmt.groupByClause, p.parseExpr(0))
 			for p.token == COMMA {
@@ -105,21 +327,20 @@ func (p *Parser) parseSelectStmt() SelectStmt {
 				stmt.groupByClause = append(stmt.groupByClause, p.parseExpr(0))
 			}
 		case HAVING:
+			p.next()
 			stmt.havingClause = p.parseExpr(0)
 		case WINDOW:
-			panic("WINDOW clause not currently supported")
-		case ORDER:
-			p.assertAndConsumeToken(BY)
-			stmt.orderByClause = append(stmt.orderByClause, p.parseOrderingTerm())
-			for p.token == COMMA {
-				p.next()
-				stmt.orderByClause = append(stmt.orderByClause, p.parseOrderingTerm())
-			}
-		case LIMIT:
-			stmt.limitClause.count = p.parseExpr(0)
-			if p.token == OFFSET || p.token == COMMA {
+			p.next()
+			for {
+				name := p.consumeIdentifier()
+				p.assertAndConsumeToken(AS)
+				window := p.parseWindowDefn()
+				window.name = name
+				stmt.windowClause = append(stmt.windowClause, *window)
+				if p.token != COMMA {
+					break
+				}
 				p.next()
-				stmt.limitClause.skip = p.parseExpr(0)
 			}
 		default:
 			return stmt
@@ -138,20 +359,44 @@ func (p *Parser) parseResultColumn() []ResultColumn {
 	return projection
 }
 
-// column ::= * | table-name '.' '*' | expr [ AS alias ]
+// column ::= * | table-name '.' '*' | expr [ [ AS ] alias ]
 func (p *Parser) parseColumn() ResultColumn {
 	switch p.token {
 	case STAR: // *
 		p.next()
 		return ResultColumn{expr: &StarExpr{}}
-	default: // table-name '.' '*' | expr [ AS alias ]
+	default: // table-name '.' '*' | expr [ [ AS ] alias ]
+		start, references, arrows := p.pos.Offset, len(p.references), len(p.arrows)
+		quoted := p.isQuotedIdentifier()
 		expr := p.parseExpr(0)
+		end := p.end
+
+		// A double quoted string on its own names a column, such as one
+		// clashing with a keyword.
+		if literal, ok := expr.(*LiteralExpr); ok && quoted {
+			expr = &IdentifierExpr{value: Path{{Name: literal.value}}.String()}
+		}
 		column := ResultColumn{expr: expr}
 		if p.token == AS {
 			p.next()
+			if p.token != IDENTIFIER && p.token != STRING_LITERAL && !isFallback(p.token) {
+				p.expected("an alias")
+			}
+			column.alias = p.value
+			p.next()
+		} else if p.token == IDENTIFIER || p.token == STRING_LITERAL {
 			column.alias = p.value
 			p.next()
 		}
+
+		// SQLite names columns without an alias after their text, which is
+		// rewritten where it has paths or arrows, so those are named after
+		// their original text instead. Plain column references are named
+		// after the column.
+		_, isIdentifier := expr.(*IdentifierExpr)
+		if column.alias == "" && !isIdentifier && (len(p.references) > references || len(p.arrows) > arrows) {
+			p.unnamed = append(p.unnamed, span{start: start, end: end})
+		}
 		return column
 	}
 }
@@ -159,7 +404,7 @@ func (p *Parser) parseColumn() ResultColumn {
 // table-list ::= table-or-subquery [, table-or-subquery] | join-clause
 //
 // join-clause ::= table_or_subquery [ join-operator table-or-subquery join-constraint ]
-// join-operator ::= , | [NATURAL] [LEFT | RIGHT | FULLl] [OUTER | INNER | CROSS] JOIN
+// join-operator ::= , | [NATURAL] [ LEFT [OUTER] | RIGHT [OUTER] | FULL [OUTER] | INNER | CROSS ] JOIN
 // join-args ::= [ON expr] [USING ( column-name [, column-name]* )]
 func (p *Parser) parseTableList() []JoinedTable {
 	tableList := make([]JoinedTable, 0)
@@ -172,16 +417,23 @@ func (p *Parser) parseTableList() []JoinedTable {
 			tableExpr = p.parseTableExpr()
 			tableList = append(tableList, tableExpr)
 			continue
-		case NATURAL, INNER, LEFT, RIGHT, FULL, UNION, JOIN:
+		case NATURAL, INNER, CROSS, LEFT, RIGHT, FULL, JOIN:
 			natural := p.token == NATURAL
+			if natural {
+				p.next()
+			}
 			var joinType JoinType
 			switch p.token {
 			case JOIN:
 				p.next()
+			case CROSS:
+				joinType = Cross
+				p.next()
+				p.assertAndConsumeToken(JOIN)
 			case INNER:
 				joinType = Inner
 				p.next()
-				p.next()
+				p.assertAndConsumeToken(JOIN)
 			case LEFT:
 				p.next()
 				if p.token == OUTER {
@@ -190,7 +442,7 @@ func (p *Parser) parseTableList() []JoinedTable {
 				} else {
 					joinType = Left
 				}
-				p.next()
+				p.assertAndConsumeToken(JOIN)
 			case RIGHT:
 				p.next()
 				if p.token == OUTER {
@@ -199,7 +451,7 @@ func (p *Parser) parseTableList() []JoinedTable {
 				} else {
 					joinType = Right
 				}
-				p.next()
+				p.assertAndConsumeToken(JOIN)
 			case FULL:
 				p.next()
 				if p.token == OUTER {
@@ -208,7 +460,9 @@ func (p *Parser) parseTableList() []JoinedTable {
 				} else {
 					joinType = Full
 				}
-				p.next()
+				p.assertAndConsumeToken(JOIN)
+			default:
+				p.expected(JOIN.String())
 			}
 
 			source := p.parseTableExpr()
@@ -226,10 +480,7 @@ func (p *Parser) parseTableList() []JoinedTable {
 				continue
 			} else if p.token == USING {
 				p.next()
-				if p.token != LP {
-					panic("expected (")
-				}
-				p.next()
+				p.assertAndConsumeToken(LP)
 				columns := make([]Expr, 0)
 				for {
 					columns = append(columns, p.parseExpr(0))
@@ -238,6 +489,7 @@ func (p *Parser) parseTableList() []JoinedTable {
 					}
 					p.next()
 				}
+				p.assertAndConsumeToken(RP)
 				join := Join{
 					source:       source,
 					natural:      natural,
@@ -245,13 +497,23 @@ func (p *Parser) parseTableList() []JoinedTable {
 					namedColumns: columns,
 				}
 				tableList[len(tableList)-1].joins = append(tableList[len(tableList)-1].joins, join)
+				continue
+			} else if _, ok := source.source.(*FunctionCallExpr); ok || natural || joinType == Cross {
+				// Natural and cross joins need no constraint, nor do
+				// table-valued functions, which are joined on their arguments.
+				join := Join{
+					source:   source,
+					natural:  natural,
+					joinType: joinType,
+				}
+				tableList[len(tableList)-1].joins = append(tableList[len(tableList)-1].joins, join)
+				continue
 			} else {
-				panic("expected ON | USING")
+				p.expected("ON or USING")
 			}
 		default:
 			return tableList
 		}
-		p.next()
 	}
 }
 
@@ -260,42 +522,57 @@ func (p *Parser) parseTableList() []JoinedTable {
 //						 | ( (table-or-subquery [, table-or-subquery]*) | join-clause )
 //						 | (select-stmt) [AS alias]
 func (p *Parser) parseTableExpr() JoinedTable {
+	if p.token != IDENTIFIER && p.token != LP && !p.isQuotedIdentifier() && !isFallback(p.token) {
+		p.expected("a table name or sub-query")
+	}
+
 	token, value := p.token, p.value
+	if isFallback(token) || p.isQuotedIdentifier() {
+		token = IDENTIFIER
+	}
 	switch p.next(); token {
 	case IDENTIFIER:
 		switch p.token {
-		case AS:
-			p.next()
-			fallthrough
-		case IDENTIFIER:
-			joinedTable := JoinedTable{source: &IdentifierExpr{value: value, kind: Table, alias: p.value}}
+		case LP:
+			functionCallExpr := &FunctionCallExpr{function: strings.ToLower(value)}
 			p.next()
-			return joinedTable
+			for p.token != RP {
+				functionCallExpr.operands = append(functionCallExpr.operands, p.parseExpr(0))
+				if p.token != COMMA {
+					break
+				}
+				p.next()
+			}
+			p.assertAndConsumeToken(RP)
+
+			return JoinedTable{source: functionCallExpr, alias: p.parseTableAlias()}
 		default:
-			return JoinedTable{source: &IdentifierExpr{value: value, kind: Table}}
+			return JoinedTable{source: &IdentifierExpr{value: value, kind: p.tableKind(value), alias: p.parseTableAlias()}}
 		}
 	case LP:
-		p.assertAndConsumeToken(SELECT)
 		stmt := p.parseSelectStmt()
-		// RP may not have been consumed yet.
-		if p.token == RP {
-			p.next()
-		}
-
-		// Consume [[AS] alias]
-		if p.token == AS {
-			p.next()
-			p.assertAndConsumeToken(IDENTIFIER)
-		} else if p.token == IDENTIFIER {
-			p.next()
-		}
+		p.assertAndConsumeToken(RP)
 
-		return JoinedTable{source: &stmt}
+		return JoinedTable{source: &stmt, alias: p.parseTableAlias()}
 	default:
-		panic(fmt.Sprintf("unexpected token: %s", p.token))
+		panic("unreachable")
 	}
 }
 
+// table-alias ::= [ [ AS ] alias ]
+func (p *Parser) parseTableAlias() string {
+	if p.token == AS {
+		p.next()
+		return p.consumeIdentifier()
+	}
+	if p.token == IDENTIFIER || p.isQuotedIdentifier() {
+		alias := p.value
+		p.next()
+		return alias
+	}
+	return ""
+}
+
 // ordering-term ::= expr [COLLATE collation-name] [ ASC | DESC ]
 func (p *Parser) parseOrderingTerm() OrderByExpr {
 	orderingTerm := OrderByExpr{}
@@ -315,6 +592,99 @@ func (p *Parser) parseOrderingTerm() OrderByExpr {
 	return orderingTerm
 }
 
+// window-defn ::= ( [ base-window-name ]
+//                   [ PARTITION BY expr [, expr ]* ]
+//                   [ ORDER BY ordering-term [, ordering-term ]* ]
+//                   [ frame-spec ] )
+func (p *Parser) parseWindowDefn() *WindowDefn {
+	p.assertAndConsumeToken(LP)
+	window := &WindowDefn{}
+	if p.token == IDENTIFIER {
+		window.base = p.value
+		p.next()
+	}
+	if p.token == PARTITION {
+		p.next()
+		p.assertAndConsumeToken(BY)
+		window.partitionBy = append(window.partitionBy, p.parseExpr(0))
+		for p.token == COMMA {
+			p.next()
+			window.partitionBy = append(window.partitionBy, p.parseExpr(0))
+		}
+	}
+	if p.token == ORDER {
+		p.next()
+		p.assertAndConsumeToken(BY)
+		window.orderBy = append(window.orderBy, p.parseOrderingTerm())
+		for p.token == COMMA {
+			p.next()
+			window.orderBy = append(window.orderBy, p.parseOrderingTerm())
+		}
+	}
+	if p.token == RANGE || p.token == ROWS || p.token == GROUPS {
+		window.frame = p.parseFrameSpec()
+	}
+	p.assertAndConsumeToken(RP)
+	return window
+}
+
+// frame-spec ::= ( RANGE | ROWS | GROUPS ) ( BETWEEN frame-bound AND frame-bound | frame-bound )
+//                [ EXCLUDE ( NO OTHERS | CURRENT ROW | GROUP | TIES ) ]
+func (p *Parser) parseFrameSpec() *FrameSpec {
+	frame := &FrameSpec{unit: p.token}
+	p.next()
+	if p.token == BETWEEN {
+		p.next()
+		frame.start = p.parseFrameBound()
+		p.assertAndConsumeToken(AND)
+		end := p.parseFrameBound()
+		frame.end = &end
+	} else {
+		frame.start = p.parseFrameBound()
+	}
+
+	if p.token == EXCLUDE {
+		p.next()
+		switch frame.exclude = p.token; p.token {
+		case NO:
+			p.next()
+			p.assertAndConsumeToken(OTHERS)
+		case CURRENT:
+			p.next()
+			p.assertAndConsumeToken(ROW)
+		case GROUP, TIES:
+			p.next()
+		default:
+			p.expected("NO OTHERS, CURRENT ROW, GROUP or TIES")
+		}
+	}
+	return frame
+}
+
+// frame-bound ::= UNBOUNDED ( PRECEDING | FOLLOWING ) | CURRENT ROW | expr ( PRECEDING | FOLLOWING )
+func (p *Parser) parseFrameBound() FrameBound {
+	bound := FrameBound{}
+	switch p.token {
+	case CURRENT:
+		p.next()
+		p.assertAndConsumeToken(ROW)
+		bound.direction = CURRENT
+		return bound
+	case UNBOUNDED:
+		bound.unbounded = true
+		p.next()
+	default:
+		bound.expr = p.parseExpr(0)
+	}
+
+	if p.token != PRECEDING && p.token != FOLLOWING {
+		p.expected("PRECEDING or FOLLOWING")
+	}
+	bound.direction = p.token
+	p.next()
+	return bound
+}
+
 // expr ::= literal_value
 // 	    	| bind_parameter
 //  	    | [ [ database_name '.' ] table_name '.' ] column_name
@@ -335,47 +705,131 @@ func (p *Parser) parseOrderingTerm() OrderByExpr {
 //
 // Operator precedence parsing.
 func (p *Parser) parseExpr(power int) Expr {
+	start := p.pos.Offset
 	expr := p.parsePrefix()
 	for {
 		next := precedence(p.token)
 		if next <= power {
 			break
 		}
+
+		token, operator := p.token, p.pos.Offset
 		expr = p.parseInfix(next, expr)
+		if token == ARROW || token == ARROW2 {
+			p.arrows = append(p.arrows, arrow{
+				start:    start,
+				operator: operator,
+				end:      p.end,
+				token:    token,
+			})
+		}
 	}
 	return expr
 }
 
+// path ::= name [ '.' name | '[' [ - ] integer ']' ]* [ '.' '*' ]
+//
+// The first name of a path may qualify the rest with the name of a table,
+// which is only known once the whole statement has been parsed.
+func (p *Parser) parsePath(name string, pos Position) *IdentifierExpr {
+	path := Path{{Name: name}}
+	first := p.end
+	for {
+		switch p.token {
+		case DOT:
+			p.next()
+			if p.token == STAR {
+				p.next()
+				return &IdentifierExpr{value: path.String() + ".*"}
+			}
+			path = append(path, PathElement{Name: p.consumeIdentifier()})
+			continue
+		case LBRACKET:
+			p.next()
+			sign := 1
+			if p.token == MINUS {
+				sign = -1
+				p.next()
+			}
+			index, err := strconv.Atoi(p.value)
+			if p.token != NUMERIC_LITERAL || err != nil {
+				p.expected("an array index")
+			}
+			p.next()
+			p.assertAndConsumeToken(RBRACKET)
+			path = append(path, PathElement{Index: sign * index, IsIndex: true})
+			continue
+		}
+		break
+	}
+
+	identifier := &IdentifierExpr{value: path.String()}
+	if len(path) > 1 {
+		p.references = append(p.references, reference{
+			start: pos.Offset,
+			first: first,
+			end:   p.end,
+			value: identifier.value,
+		})
+	}
+	return identifier
+}
+
 func (p *Parser) parsePrefix() Expr {
-	token, value := p.token, p.value
+	token, value, pos := p.token, p.value, p.pos
+	if isFallback(token) {
+		token = IDENTIFIER
+	}
 	switch p.next(); token {
 	case IDENTIFIER:
-		identifier := value
-		if p.token == DOT {
-			identifier += "."
+		if p.token == LP {
+			functionCallExpr := &FunctionCallExpr{function: strings.ToLower(value)}
 			p.next()
-			if p.token == IDENTIFIER || p.token == STAR {
-				identifier += p.value
+
+			if p.token == DISTINCT {
+				functionCallExpr.distinct = true
 				p.next()
 			}
-		} else if p.token == LP {
-			functionCallExpr := &FunctionCallExpr{function: strings.ToLower(value)}
-			p.next()
 
-			// Horrible...This needs to be a more generic method for parsing a
-			// comma separated list of expressions.
-			operands := p.parseResultColumn()
-			for _, operand := range operands {
-				functionCallExpr.operands = append(functionCallExpr.operands, operand.expr)
+			if p.token == STAR {
+				functionCallExpr.operands = append(functionCallExpr.operands, &StarExpr{})
+				p.next()
+			}
+			for p.token != RP && p.token != EOF {
+				functionCallExpr.operands = append(functionCallExpr.operands, p.parseExpr(0))
+				if p.token != COMMA {
+					break
+				}
+				p.next()
 			}
 			p.assertAndConsumeToken(RP)
+
+			if p.token == FILTER {
+				p.next()
+				p.assertAndConsumeToken(LP)
+				p.assertAndConsumeToken(WHERE)
+				functionCallExpr.filter = p.parseExpr(0)
+				p.assertAndConsumeToken(RP)
+			}
+			if p.token == OVER {
+				p.next()
+				if p.token == LP {
+					functionCallExpr.over = p.parseWindowDefn()
+				} else {
+					functionCallExpr.over = &WindowDefn{name: p.consumeIdentifier()}
+				}
+			}
 			return functionCallExpr
 		}
-		return &IdentifierExpr{value: identifier}
+		return p.parsePath(value, pos)
 	case NUMERIC_LITERAL:
 		return &LiteralExpr{value: value, kind: None}
 	case STRING_LITERAL:
-		return &LiteralExpr{value: value, kind: Column}
+		// Double quoted strings followed by a path name a column.
+		if p.scanner.input[pos.Offset] == '"' && (p.token == DOT || p.token == LBRACKET) {
+			return p.parsePath(value, pos)
+		}
+		return &LiteralExpr{value: value, kind: None}
 	case NOT:
 		if p.token == EXISTS {
 			exists := p.parsePrefix()
@@ -385,6 +839,7 @@ func (p *Parser) parsePrefix() Expr {
 		if p.token == LP {
 			p.next()
 			expr := p.parseExpr(0)
+			p.assertAndConsumeToken(RP)
 			return &UnaryExpr{operator: NOT, expr: expr}
 		}
 		fallthrough
@@ -400,8 +855,8 @@ func (p *Parser) parsePrefix() Expr {
 		return &CastExpr{typeName: alias, expr: left}
 	case EXISTS:
 		p.assertAndConsumeToken(LP)
-		p.assertAndConsumeToken(SELECT)
 		selectStmt := p.parseSelectStmt()
+		p.assertAndConsumeToken(RP)
 		return &ExistsExpr{selectStmt: &selectStmt}
 	case CASE:
 		caseExpr := CaseExpr{}
@@ -410,9 +865,9 @@ func (p *Parser) parsePrefix() Expr {
 		}
 
 		for !(p.token == END || p.token == ELSE) {
-			p.next()
+			p.assertAndConsumeToken(WHEN)
 			caseExpr.when = append(caseExpr.when, p.parseExpr(0))
-			p.next()
+			p.assertAndConsumeToken(THEN)
 			caseExpr.then = append(caseExpr.then, p.parseExpr(0))
 		}
 
@@ -420,11 +875,12 @@ func (p *Parser) parsePrefix() Expr {
 			p.next()
 			caseExpr.elseExpr = p.parseExpr(0)
 		}
+		p.assertAndConsumeToken(END)
 		return &caseExpr
 	case LP:
-		if p.token == SELECT {
-			p.next()
+		if p.token == SELECT || p.token == WITH {
 			stmt := p.parseSelectStmt()
+			p.assertAndConsumeToken(RP)
 			return &stmt
 		}
 
@@ -432,7 +888,9 @@ func (p *Parser) parsePrefix() Expr {
 		p.assertAndConsumeToken(RP)
 		return expr
 	default:
-		panic("unsupported expression.")
+		p.pos, p.token, p.value = pos, token, value
+		p.expected("an expression")
+		return nil
 	}
 }
 
@@ -459,16 +917,62 @@ func (p *Parser) parseInfix(power int, left Expr) Expr {
 			return &StringMatchExpr{operator: operator, inverse: true, left: left, right: right}
 		case BETWEEN:
 			p.next()
-			rangeExpr := p.parseExpr(0).(*BinaryExpr)
+			rangeExpr := p.parseRange()
 			return &BetweenExpr{inverse: true, expr: left, left: rangeExpr.left, right: rangeExpr.right}
+		case IN:
+			p.next()
+			inExpr := p.parseIn(left)
+			inExpr.inverse = true
+			return inExpr
 		}
 		right := p.parseExpr(power)
 		return &BinaryExpr{operator: token, left: left, right: right}
 	case BETWEEN:
-		rangeExpr := p.parseExpr(0).(*BinaryExpr)
+		rangeExpr := p.parseRange()
 		return &BetweenExpr{expr: left, left: rangeExpr.left, right: rangeExpr.right}
+	case IN:
+		return p.parseIn(left)
 	default:
 		right := p.parseExpr(power)
 		return &BinaryExpr{operator: token, left: left, right: right}
 	}
 }
+
+// parseIn parses the operand of an IN expression.
+//
+// in-expr ::= expr [ NOT ] IN ( '(' [ select-stmt | expr [, expr ]* ] ')' | table-name )
+func (p *Parser) parseIn(left Expr) *InExpr {
+	inExpr := &InExpr{expr: left}
+	if p.token != LP {
+		name := p.consumeIdentifier()
+		inExpr.table = &IdentifierExpr{value: name, kind: p.tableKind(name)}
+		return inExpr
+	}
+
+	p.next()
+	if p.token == SELECT || p.token == WITH {
+		stmt := p.parseSelectStmt()
+		inExpr.selectStmt = &stmt
+	} else {
+		for p.token != RP {
+			inExpr.list = append(inExpr.list, p.parseExpr(0))
+			if p.token != COMMA {
+				break
+			}
+			p.next()
+		}
+	}
+	p.assertAndConsumeToken(RP)
+	return inExpr
+}
+
+// parseRange parses the 'expr AND expr' operand of a BETWEEN expression.
+func (p *Parser) parseRange() *BinaryExpr {
+	token, value, pos := p.token, p.value, p.pos
+	rangeExpr, ok := p.parseExpr(0).(*BinaryExpr)
+	if !ok || rangeExpr.operator != AND {
+		p.pos, p.token, p.value = pos, token, value
+		p.errorf("expected 'expr AND expr' after BETWEEN")
+	}
+	return rangeExpr
+}
@@ -10,7 +10,11 @@ func parseStatement(stmt string) SelectStmt {
 	parser := Parser{
 		scanner: scanner,
 	}
-	return parser.Parse()
+	selectStmt, err := parser.Parse()
+	if err != nil {
+		panic(err)
+	}
+	return selectStmt
 }
 
 func TestParseExpr(t *testing.T) {
@@ -29,7 +33,17 @@ func TestParseExpr(t *testing.T) {
 			&IdentifierExpr{value: "b"},
 			&IdentifierExpr{value: "foo"},
 		}},
-		//{"SELECT 'hello, world';", []Expr{&LiteralExpr{value: "hello, world"}}},
+		{"SELECT 'hello, world';", []Expr{&LiteralExpr{value: "hello, world"}}},
+		{"SELECT 'a', \"b\";", []Expr{&LiteralExpr{value: "a"}, &IdentifierExpr{value: "b"}}},
+		// paths
+		{"SELECT about.metric, tags[0], items[-1].price, \"weird key\".x, \"a.b\", t.*;", []Expr{
+			&IdentifierExpr{value: "about.metric"},
+			&IdentifierExpr{value: "tags[0]"},
+			&IdentifierExpr{value: "items[-1].price"},
+			&IdentifierExpr{value: "weird key.x"},
+			&IdentifierExpr{value: `"a.b"`},
+			&IdentifierExpr{value: "t.*"},
+		}},
 		{"SELECT α, γ, δ, ϵ, ζ, θ, μ, ψ;", []Expr{
 			&IdentifierExpr{value: "α"},
 			&IdentifierExpr{value: "γ"},
@@ -93,6 +107,19 @@ func TestParseExpr(t *testing.T) {
 		}},
 		// binary operators, precedence order
 		{"SELECT a || b;", []Expr{&BinaryExpr{operator: CONCAT, left: &IdentifierExpr{value: "a"}, right: &IdentifierExpr{value: "b"}}}},
+		{"SELECT a -> b;", []Expr{&BinaryExpr{operator: ARROW, left: &IdentifierExpr{value: "a"}, right: &IdentifierExpr{value: "b"}}}},
+		{"SELECT a ->> b;", []Expr{&BinaryExpr{operator: ARROW2, left: &IdentifierExpr{value: "a"}, right: &IdentifierExpr{value: "b"}}}},
+		{"SELECT a->'b'->>0 = -c;", []Expr{
+			&BinaryExpr{
+				operator: EQ,
+				left: &BinaryExpr{
+					operator: ARROW2,
+					left:     &BinaryExpr{operator: ARROW, left: &IdentifierExpr{value: "a"}, right: &LiteralExpr{value: "b"}},
+					right:    &LiteralExpr{value: "0"},
+				},
+				right: &UnaryExpr{operator: MINUS, expr: &IdentifierExpr{value: "c"}},
+			},
+		}},
 		{"SELECT a * b;", []Expr{&BinaryExpr{operator: STAR, left: &IdentifierExpr{value: "a"}, right: &IdentifierExpr{value: "b"}}}},
 		{"SELECT a / b;", []Expr{&BinaryExpr{operator: SLASH, left: &IdentifierExpr{value: "a"}, right: &IdentifierExpr{value: "b"}}}},
 		{"SELECT a % b;", []Expr{&BinaryExpr{operator: REM, left: &IdentifierExpr{value: "a"}, right: &IdentifierExpr{value: "b"}}}},
@@ -111,7 +138,7 @@ func TestParseExpr(t *testing.T) {
 		{"SELECT a != b;", []Expr{&BinaryExpr{operator: NE, left: &IdentifierExpr{value: "a"}, right: &IdentifierExpr{value: "b"}}}},
 		{"SELECT a <> b;", []Expr{&BinaryExpr{operator: NE, left: &IdentifierExpr{value: "a"}, right: &IdentifierExpr{value: "b"}}}},
 		{"SELECT a NOT b;", []Expr{&BinaryExpr{operator: NOT, left: &IdentifierExpr{value: "a"}, right: &IdentifierExpr{value: "b"}}}},
-		{"SELECT a IN b;", []Expr{&BinaryExpr{operator: IN, left: &IdentifierExpr{value: "a"}, right: &IdentifierExpr{value: "b"}}}},
+		{"SELECT a IN b;", []Expr{&InExpr{expr: &IdentifierExpr{value: "a"}, table: &IdentifierExpr{value: "b", kind: Table}}}},
 		//	{"SELECT a LIKE b;", []Expr{&BinaryExpr{operator: LIKE, left: IdentifierExpr{value: "a"}, right: IdentifierExpr{value: "b"}}}},
 		//	{"SELECT a GLOB b;", []Expr{&BinaryExpr{operator: GLOB, left: IdentifierExpr{value: "a"}, right: IdentifierExpr{value: "b"}}}},
 		//	{"SELECT a MATCH b;", []Expr{&BinaryExpr{operator: MATCH, left: IdentifierExpr{value: "a"}, right: IdentifierExpr{value: "b"}}}},
@@ -276,52 +303,85 @@ func TestParseExpr(t *testing.T) {
 }
 
 func TestParseInExpr(t *testing.T) {
-	/*	// Arrange
-		stmt := "SELECT EXISTS(SELECT a FROM b WHERE a == 3);"
-		expected := SelectStmt{
-			resultColumn: []ResultColumn{{
-				expr: &ExistsExpr{
-					selectStmt: SelectStmt{
-						resultColumn: []ResultColumn{{expr: &IdentifierExpr{value: "a"}}},
-						fromClause:   TableExpr{source: &IdentifierExpr{value: "b", kind: Table}},
-						whereClause: &BinaryExpr{
-							operator: EQ,
-							left:     &IdentifierExpr{value: "a"},
-							right:    &LiteralExpr{value: "3"},
-						},
-					},
+	type TestCase struct {
+		statement string
+		expected  Expr
+	}
+
+	var cases = [...]TestCase{
+		{"SELECT a IN (1, b + 2, 'c');", &InExpr{
+			expr: &IdentifierExpr{value: "a"},
+			list: []Expr{
+				&LiteralExpr{value: "1"},
+				&BinaryExpr{operator: PLUS, left: &IdentifierExpr{value: "b"}, right: &LiteralExpr{value: "2"}},
+				&LiteralExpr{value: "c"},
+			},
+		}},
+		{"SELECT a IN ();", &InExpr{expr: &IdentifierExpr{value: "a"}}},
+		{"SELECT a IN (SELECT b FROM c WHERE b > 3);", &InExpr{
+			expr: &IdentifierExpr{value: "a"},
+			selectStmt: &SelectStmt{
+				resultColumn: []ResultColumn{{expr: &IdentifierExpr{value: "b"}}},
+				fromClause:   []JoinedTable{{source: &IdentifierExpr{value: "c", kind: Table}}},
+				whereClause: &BinaryExpr{
+					operator: GT,
+					left:     &IdentifierExpr{value: "b"},
+					right:    &LiteralExpr{value: "3"},
 				},
-			}},
-		}
+			},
+		}},
+		{"SELECT a + 1 IN [];", &InExpr{
+			expr:  &BinaryExpr{operator: PLUS, left: &IdentifierExpr{value: "a"}, right: &LiteralExpr{value: "1"}},
+			table: &IdentifierExpr{value: "[]", kind: Table},
+		}},
+		{"SELECT a IN (b) AND c;", &BinaryExpr{
+			operator: AND,
+			left:     &InExpr{expr: &IdentifierExpr{value: "a"}, list: []Expr{&IdentifierExpr{value: "b"}}},
+			right:    &IdentifierExpr{value: "c"},
+		}},
+	}
 
-		// Act / Assert
-		parsedStmt := parseStatement(stmt)
-		checkStatement(t, parsedStmt, expected)*/
+	for _, _case := range cases {
+		stmt := parseStatement(_case.statement)
+		if len(stmt.resultColumn) != 1 || !eqExpr(stmt.resultColumn[0].expr, _case.expected) {
+			t.Errorf("unexpected expression parsing: %s", _case.statement)
+		}
+	}
 }
 
 func TestParseInverseInExpr(t *testing.T) {
-	/*	// Arrange
-		stmt := "SELECT NOT EXISTS(SELECT a FROM b WHERE a == 3);"
-		expected := SelectStmt{
-			resultColumn: []ResultColumn{{
-				expr: &ExistsExpr{
-					inverse: true,
-					selectStmt: SelectStmt{
-						resultColumn: []ResultColumn{{expr: &IdentifierExpr{value: "a"}}},
-						fromClause:   []TableExpr{source: &IdentifierExpr{value: "b", kind: Table}},
-						whereClause: &BinaryExpr{
-							operator: EQ,
-							left:     &IdentifierExpr{value: "a"},
-							right:    &LiteralExpr{value: "3"},
-						},
-					},
-				},
-			}},
-		}
+	type TestCase struct {
+		statement string
+		expected  Expr
+	}
+
+	var cases = [...]TestCase{
+		{"SELECT a NOT IN (1, 2);", &InExpr{
+			inverse: true,
+			expr:    &IdentifierExpr{value: "a"},
+			list:    []Expr{&LiteralExpr{value: "1"}, &LiteralExpr{value: "2"}},
+		}},
+		{"SELECT a NOT IN (SELECT b FROM c);", &InExpr{
+			inverse: true,
+			expr:    &IdentifierExpr{value: "a"},
+			selectStmt: &SelectStmt{
+				resultColumn: []ResultColumn{{expr: &IdentifierExpr{value: "b"}}},
+				fromClause:   []JoinedTable{{source: &IdentifierExpr{value: "c", kind: Table}}},
+			},
+		}},
+		{"WITH b AS (SELECT 1 AS c) SELECT a NOT IN b;", &InExpr{
+			inverse: true,
+			expr:    &IdentifierExpr{value: "a"},
+			table:   &IdentifierExpr{value: "b", kind: CommonTable},
+		}},
+	}
 
-		// Act / Assert
-		parsedStmt := parseStatement(stmt)
-		checkStatement(t, parsedStmt, expected)*/
+	for _, _case := range cases {
+		stmt := parseStatement(_case.statement)
+		if len(stmt.resultColumn) != 1 || !eqExpr(stmt.resultColumn[0].expr, _case.expected) {
+			t.Errorf("unexpected expression parsing: %s", _case.statement)
+		}
+	}
 }
 
 func TestParseDistinctAll(t *testing.T) {
@@ -360,7 +420,7 @@ func TestParseResultColumns(t *testing.T) {
 			{expr: &IdentifierExpr{value: "b"}},
 			{expr: &IdentifierExpr{value: "c"}},
 		}},
-		{"SELECT test_table.*;", []ResultColumn{{expr: &IdentifierExpr{value: "test_table."}}}},
+		{"SELECT test_table.*;", []ResultColumn{{expr: &IdentifierExpr{value: "test_table.*"}}}},
 		{"SELECT test_column_a AS a, test_column_b AS b;", []ResultColumn{
 			{expr: &IdentifierExpr{value: "test_column_a"}, alias: "a"},
 			{expr: &IdentifierExpr{value: "test_column_b"}, alias: "b"},
@@ -504,6 +564,46 @@ func TestParseTableList(t *testing.T) {
 				},
 			},
 		}},
+		{"SELECT * FROM a, each(a.b, c) AS d;", []JoinedTable{
+			{source: &IdentifierExpr{value: "a", kind: Table}},
+			{source: &FunctionCallExpr{
+				function: "each",
+				operands: []Expr{
+					&IdentifierExpr{value: "a.b", kind: Column},
+					&IdentifierExpr{value: "c", kind: Column},
+				},
+			}, alias: "d"},
+		}},
+		{"SELECT key FROM a LEFT JOIN each(a.b);", []JoinedTable{
+			{
+				source: &IdentifierExpr{value: "a", kind: Table},
+				joins: []Join{
+					{
+						source: JoinedTable{source: &FunctionCallExpr{
+							function: "each",
+							operands: []Expr{&IdentifierExpr{value: "a.b", kind: Column}},
+						}},
+						joinType: Left,
+					},
+				},
+			},
+		}},
+		{"SELECT * FROM a NATURAL LEFT JOIN b CROSS JOIN c;", []JoinedTable{
+			{
+				source: &IdentifierExpr{value: "a", kind: Table},
+				joins: []Join{
+					{
+						source:   JoinedTable{source: &IdentifierExpr{value: "b", kind: Table}},
+						natural:  true,
+						joinType: Left,
+					},
+					{
+						source:   JoinedTable{source: &IdentifierExpr{value: "c", kind: Table}},
+						joinType: Cross,
+					},
+				},
+			},
+		}},
 	}
 	//"SELECT * FROM a LEFT JOIN (SELECT x AS y FROM b) ON c WHERE NOT(y='a');"
 
@@ -682,6 +782,234 @@ func TestLimitClause(t *testing.T) {
 	}
 }
 
+func TestCompoundSelect(t *testing.T) {
+	type TestCase struct {
+		statement string
+		expected  SelectStmt
+	}
+
+	var cases = [...]TestCase{
+		{"SELECT a FROM b UNION SELECT c FROM d;", SelectStmt{
+			resultColumn: []ResultColumn{{expr: &IdentifierExpr{value: "a"}}},
+			fromClause:   []JoinedTable{{source: &IdentifierExpr{value: "b", kind: Table}}},
+			compound: []CompoundSelect{{
+				operator: Union,
+				stmt: &SelectStmt{
+					resultColumn: []ResultColumn{{expr: &IdentifierExpr{value: "c"}}},
+					fromClause:   []JoinedTable{{source: &IdentifierExpr{value: "d", kind: Table}}},
+				},
+			}},
+		}},
+		{"SELECT a FROM b UNION ALL SELECT DISTINCT c FROM d WHERE c > 1 INTERSECT SELECT e EXCEPT SELECT f ORDER BY a LIMIT 2;", SelectStmt{
+			resultColumn: []ResultColumn{{expr: &IdentifierExpr{value: "a"}}},
+			fromClause:   []JoinedTable{{source: &IdentifierExpr{value: "b", kind: Table}}},
+			compound: []CompoundSelect{
+				{
+					operator: UnionAll,
+					stmt: &SelectStmt{
+						isDistinct:   true,
+						resultColumn: []ResultColumn{{expr: &IdentifierExpr{value: "c"}}},
+						fromClause:   []JoinedTable{{source: &IdentifierExpr{value: "d", kind: Table}}},
+						whereClause: &BinaryExpr{
+							operator: GT,
+							left:     &IdentifierExpr{value: "c"},
+							right:    &LiteralExpr{value: "1"},
+						},
+					},
+				},
+				{
+					operator: Intersect,
+					stmt:     &SelectStmt{resultColumn: []ResultColumn{{expr: &IdentifierExpr{value: "e"}}}},
+				},
+				{
+					operator: Except,
+					stmt:     &SelectStmt{resultColumn: []ResultColumn{{expr: &IdentifierExpr{value: "f"}}}},
+				},
+			},
+			orderByClause: []OrderByExpr{{expr: &IdentifierExpr{value: "a"}}},
+			limitClause:   LimitExpr{count: &LiteralExpr{value: "2"}},
+		}},
+		{"SELECT a FROM (SELECT b UNION SELECT c LIMIT 1);", SelectStmt{
+			resultColumn: []ResultColumn{{expr: &IdentifierExpr{value: "a"}}},
+			fromClause: []JoinedTable{{source: &SelectStmt{
+				resultColumn: []ResultColumn{{expr: &IdentifierExpr{value: "b"}}},
+				compound: []CompoundSelect{{
+					operator: Union,
+					stmt:     &SelectStmt{resultColumn: []ResultColumn{{expr: &IdentifierExpr{value: "c"}}}},
+				}},
+				limitClause: LimitExpr{count: &LiteralExpr{value: "1"}},
+			}}},
+		}},
+	}
+
+	for _, _case := range cases {
+		stmt := parseStatement(_case.statement)
+		if !eqSelectStmt(&stmt, &_case.expected) {
+			t.Errorf("unexpected statement for %s", _case.statement)
+		}
+	}
+}
+
+func TestWithClause(t *testing.T) {
+	type TestCase struct {
+		statement string
+		expected  SelectStmt
+	}
+
+	var cases = [...]TestCase{
+		{"WITH a AS (SELECT b FROM []) SELECT b FROM a;", SelectStmt{
+			with: &WithClause{tables: []CommonTableExpr{{
+				name: "a",
+				stmt: &SelectStmt{
+					resultColumn: []ResultColumn{{expr: &IdentifierExpr{value: "b"}}},
+					fromClause:   []JoinedTable{{source: &IdentifierExpr{value: "[]", kind: Table}}},
+				},
+			}}},
+			resultColumn: []ResultColumn{{expr: &IdentifierExpr{value: "b"}}},
+			fromClause:   []JoinedTable{{source: &IdentifierExpr{value: "a", kind: CommonTable}}},
+		}},
+		{"WITH RECURSIVE t(n) AS (SELECT 1 UNION ALL SELECT n FROM t), u AS (SELECT n FROM T) SELECT n FROM u, t AS v, w;", SelectStmt{
+			with: &WithClause{
+				recursive: true,
+				tables: []CommonTableExpr{
+					{
+						name:    "t",
+						columns: []string{"n"},
+						stmt: &SelectStmt{
+							resultColumn: []ResultColumn{{expr: &LiteralExpr{value: "1"}}},
+							compound: []CompoundSelect{{
+								operator: UnionAll,
+								stmt: &SelectStmt{
+									resultColumn: []ResultColumn{{expr: &IdentifierExpr{value: "n"}}},
+									fromClause:   []JoinedTable{{source: &IdentifierExpr{value: "t", kind: CommonTable}}},
+								},
+							}},
+						},
+					},
+					{
+						name: "u",
+						stmt: &SelectStmt{
+							resultColumn: []ResultColumn{{expr: &IdentifierExpr{value: "n"}}},
+							fromClause:   []JoinedTable{{source: &IdentifierExpr{value: "T", kind: CommonTable}}},
+						},
+					},
+				},
+			},
+			resultColumn: []ResultColumn{{expr: &IdentifierExpr{value: "n"}}},
+			fromClause: []JoinedTable{
+				{source: &IdentifierExpr{value: "u", kind: CommonTable}},
+				{source: &IdentifierExpr{value: "t", kind: CommonTable, alias: "v"}},
+				{source: &IdentifierExpr{value: "w", kind: Table}},
+			},
+		}},
+		{"SELECT a FROM (WITH b AS (SELECT 1 AS a) SELECT a FROM b), b;", SelectStmt{
+			resultColumn: []ResultColumn{{expr: &IdentifierExpr{value: "a"}}},
+			fromClause: []JoinedTable{
+				{source: &SelectStmt{
+					with: &WithClause{tables: []CommonTableExpr{{
+						name: "b",
+						stmt: &SelectStmt{resultColumn: []ResultColumn{{alias: "a", expr: &LiteralExpr{value: "1"}}}},
+					}}},
+					resultColumn: []ResultColumn{{expr: &IdentifierExpr{value: "a"}}},
+					fromClause:   []JoinedTable{{source: &IdentifierExpr{value: "b", kind: CommonTable}}},
+				}},
+				{source: &IdentifierExpr{value: "b", kind: Table}},
+			},
+		}},
+	}
+
+	for _, _case := range cases {
+		stmt := parseStatement(_case.statement)
+		if !eqSelectStmt(&stmt, &_case.expected) {
+			t.Errorf("unexpected statement for %s", _case.statement)
+		}
+	}
+}
+
+func TestWindowFunctions(t *testing.T) {
+	type TestCase struct {
+		statement string
+		expected  SelectStmt
+	}
+
+	var cases = [...]TestCase{
+		{"SELECT row_number() OVER (PARTITION BY a, b ORDER BY c DESC) FROM d;", SelectStmt{
+			resultColumn: []ResultColumn{{expr: &FunctionCallExpr{
+				function: "row_number",
+				over: &WindowDefn{
+					partitionBy: []Expr{&IdentifierExpr{value: "a"}, &IdentifierExpr{value: "b"}},
+					orderBy:     []OrderByExpr{{expr: &IdentifierExpr{value: "c"}, sortOrder: DESC}},
+				},
+			}}},
+			fromClause: []JoinedTable{{source: &IdentifierExpr{value: "d", kind: Table}}},
+		}},
+		{"SELECT sum(a) OVER w, count(*) FILTER (WHERE a > 1) OVER (w ROWS 2 PRECEDING) FROM b WINDOW w AS (ORDER BY c), v AS ();", SelectStmt{
+			resultColumn: []ResultColumn{
+				{expr: &FunctionCallExpr{
+					function: "sum",
+					operands: []Expr{&IdentifierExpr{value: "a"}},
+					over:     &WindowDefn{name: "w"},
+				}},
+				{expr: &FunctionCallExpr{
+					function: "count",
+					operands: []Expr{&StarExpr{}},
+					filter:   &BinaryExpr{operator: GT, left: &IdentifierExpr{value: "a"}, right: &LiteralExpr{value: "1"}},
+					over: &WindowDefn{
+						base: "w",
+						frame: &FrameSpec{
+							unit:  ROWS,
+							start: FrameBound{expr: &LiteralExpr{value: "2"}, direction: PRECEDING},
+						},
+					},
+				}},
+			},
+			fromClause: []JoinedTable{{source: &IdentifierExpr{value: "b", kind: Table}}},
+			windowClause: []WindowDefn{
+				{name: "w", orderBy: []OrderByExpr{{expr: &IdentifierExpr{value: "c"}}}},
+				{name: "v"},
+			},
+		}},
+		{"SELECT max(a) OVER (ORDER BY b RANGE BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW EXCLUDE TIES) FROM c;", SelectStmt{
+			resultColumn: []ResultColumn{{expr: &FunctionCallExpr{
+				function: "max",
+				operands: []Expr{&IdentifierExpr{value: "a"}},
+				over: &WindowDefn{
+					orderBy: []OrderByExpr{{expr: &IdentifierExpr{value: "b"}}},
+					frame: &FrameSpec{
+						unit:    RANGE,
+						start:   FrameBound{unbounded: true, direction: PRECEDING},
+						end:     &FrameBound{direction: CURRENT},
+						exclude: TIES,
+					},
+				},
+			}}},
+			fromClause: []JoinedTable{{source: &IdentifierExpr{value: "c", kind: Table}}},
+		}},
+		{"SELECT avg(a) OVER (GROUPS BETWEEN 1 FOLLOWING AND UNBOUNDED FOLLOWING EXCLUDE NO OTHERS) FROM b;", SelectStmt{
+			resultColumn: []ResultColumn{{expr: &FunctionCallExpr{
+				function: "avg",
+				operands: []Expr{&IdentifierExpr{value: "a"}},
+				over: &WindowDefn{
+					frame: &FrameSpec{
+						unit:    GROUPS,
+						start:   FrameBound{expr: &LiteralExpr{value: "1"}, direction: FOLLOWING},
+						end:     &FrameBound{unbounded: true, direction: FOLLOWING},
+						exclude: NO,
+					},
+				},
+			}}},
+			fromClause: []JoinedTable{{source: &IdentifierExpr{value: "b", kind: Table}}},
+		}},
+	}
+
+	for _, _case := range cases {
+		stmt := parseStatement(_case.statement)
+		if !eqSelectStmt(&stmt, &_case.expected) {
+			t.Errorf("unexpected statement for %s", _case.statement)
+		}
+	}
+}
+
 func TestSubSelects(t *testing.T) {
 	type TestCase struct {
 		statement string
@@ -711,3 +1039,53 @@ func TestSubSelects(t *testing.T) {
 		}
 	}*/
 }
+
+func TestParseErrors(t *testing.T) {
+	type TestCase struct {
+		statement string
+		msg       string
+		position  Position
+	}
+
+	var cases = [...]TestCase{
+		{"UPDATE a SET b = 1;", "only SELECT statements are supported, got UPDATE", Position{0, 1, 1}},
+		{"SELECT a, b FROMM c;", "expected end of statement, got \"c\"", Position{18, 1, 19}},
+		{"SELECT a FROM b WHERE;", "expected an expression, got ;", Position{21, 1, 22}},
+		{"SELECT a FROM b JOIN c d = e;", "expected ON or USING, got =", Position{25, 1, 26}},
+		{"SELECT a FROM b\nLEFT OUTER c ON b.x = c.y;", "expected JOIN, got \"c\"", Position{27, 2, 12}},
+		{"SELECT (a + b FROM c;", "expected ), got FROM", Position{14, 1, 15}},
+		{"SELECT CAST(a AS) FROM b;", "expected IDENTIFIER, got )", Position{16, 1, 17}},
+		{"SELECT a BETWEEN b OR c;", "expected 'expr AND expr' after BETWEEN", Position{17, 1, 18}},
+		{"SELECT a FROM ;", "expected a table name or sub-query, got ;", Position{14, 1, 15}},
+		{"SELECT a @ b;", "expected end of statement, got \"@\"", Position{9, 1, 10}},
+		{"SELECT tags[x] FROM b;", "expected an array index, got \"x\"", Position{12, 1, 13}},
+		{"SELECT 'a FROM b;", "expected an expression, got \"'a FROM b;\"", Position{7, 1, 8}},
+		{"SELECT a FROM b UNION c;", "expected SELECT, got \"c\"", Position{22, 1, 23}},
+		{"SELECT a FROM b ORDER BY a UNION SELECT c;", "expected end of statement, got UNION", Position{27, 1, 28}},
+		{"SELECT a FROM b UNION JOIN c;", "expected SELECT, got JOIN", Position{22, 1, 23}},
+		{"WITH a AS SELECT 1 SELECT b;", "expected (, got SELECT", Position{10, 1, 11}},
+		{"WITH a (SELECT 1) SELECT b;", "expected IDENTIFIER, got SELECT", Position{8, 1, 9}},
+		{"WITH a AS (SELECT 1);", "expected SELECT, got ;", Position{20, 1, 21}},
+		{"SELECT sum(a) OVER (ROWS a) FROM b;", "expected PRECEDING or FOLLOWING, got )", Position{26, 1, 27}},
+		{"SELECT count(*) FILTER (a > 1) FROM b;", "expected WHERE, got \"a\"", Position{24, 1, 25}},
+		{"SELECT a IN (1, 2 FROM b;", "expected ), got FROM", Position{18, 1, 19}},
+		{"SELECT a NOT IN 1;", "expected IDENTIFIER, got \"1\"", Position{16, 1, 17}},
+		{"SELECT sum(a) OVER w FROM b WINDOW w (ORDER BY a);", "expected AS, got (", Position{37, 1, 38}},
+	}
+
+	for _, _case := range cases {
+		parser := NewParser(NewScanner([]byte(_case.statement)))
+		_, err := parser.Parse()
+
+		syntaxError, ok := err.(*SyntaxError)
+		if !ok {
+			t.Fatalf("expected a syntax error for %s", _case.statement)
+		}
+		if syntaxError.Msg != _case.msg {
+			t.Errorf("unexpected message: got %q, expected %q", syntaxError.Msg, _case.msg)
+		}
+		if syntaxError.Position != _case.position {
+			t.Errorf("unexpected position: got %+v, expected %+v", syntaxError.Position, _case.position)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package sql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PathElement is an element of the path of a JSON value, being either the name
+// of an object member or the index of an array element.
+type PathElement struct {
+	Name    string
+	Index   int // Counting back from the end of the array if negative.
+	IsIndex bool
+}
+
+// Path is the path of a JSON value within a row, as written in column
+// references such as about.metric, tags[0] or items[-1].price.
+type Path []PathElement
+
+// String returns the canonical text of a path. Member names containing
+// characters with a meaning in paths are quoted, so the text can be parsed
+// back with ParsePath.
+func (p Path) String() string {
+	var b strings.Builder
+	for i, element := range p {
+		if element.IsIndex {
+			b.WriteString("[" + strconv.Itoa(element.Index) + "]")
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		if element.Name == "" || strings.ContainsAny(element.Name, `.["`) {
+			b.WriteString(quoteIdentifier(element.Name))
+		} else {
+			b.WriteString(element.Name)
+		}
+	}
+	return b.String()
+}
+
+// ParsePath parses the canonical text of a path.
+func ParsePath(text string) (Path, error) {
+	path := make(Path, 0)
+	for i := 0; i < len(text); {
+		switch {
+		case text[i] == '[':
+			end := strings.IndexByte(text[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("malformed path %q", text)
+			}
+			index, err := strconv.Atoi(text[i+1 : i+end])
+			if err != nil {
+				return nil, fmt.Errorf("malformed path %q", text)
+			}
+			path = append(path, PathElement{Index: index, IsIndex: true})
+			i += end + 1
+			continue
+		case len(path) > 0:
+			if text[i] != '.' {
+				return nil, fmt.Errorf("malformed path %q", text)
+			}
+			i++
+		}
+
+		// Member names are either quoted, or run to the next . or [.
+		if i < len(text) && text[i] == '"' {
+			var name strings.Builder
+			for i++; ; i++ {
+				if i >= len(text) {
+					return nil, fmt.Errorf("malformed path %q", text)
+				}
+				if text[i] == '"' {
+					if i+1 >= len(text) || text[i+1] != '"' {
+						i++
+						break
+					}
+					i++
+				}
+				name.WriteByte(text[i])
+			}
+			path = append(path, PathElement{Name: name.String()})
+			continue
+		}
+
+		end := strings.IndexAny(text[i:], ".[")
+		if end < 0 {
+			end = len(text) - i
+		}
+		path = append(path, PathElement{Name: text[i : i+end]})
+		i += end
+	}
+	return path, nil
+}
+
+// qualifiers returns the names that can qualify a column reference in a
+// statement, being the names and aliases of its tables and common tables, in
+// lower case.
+func qualifiers(stmt *SelectStmt) map[string]bool {
+	qualifiers := make(map[string]bool)
+	for _, kind := range []IdentifierKind{Table, Alias, CommonTable} {
+		for _, name := range ExtractIdentifiers(stmt, kind) {
+			qualifiers[strings.ToLower(name)] = true
+		}
+	}
+	return qualifiers
+}
+
+// splitQualifier splits the name of a table or alias from the front of the
+// path of a column reference, if it has one. As in SQLite, names qualify a
+// column where they name a table, so about.metric is otherwise the metric
+// member of the about column.
+func splitQualifier(path Path, qualifiers map[string]bool) (string, Path) {
+	if len(path) < 2 || path[0].IsIndex || path[1].IsIndex || !qualifiers[strings.ToLower(path[0].Name)] {
+		return "", path
+	}
+	return path[0].Name, path[1:]
+}
+
+// quoteIdentifier quotes an identifier for use in a statement.
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,49 @@
+package sql
+
+import (
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	type TestCase struct {
+		text     string
+		expected Path
+	}
+	cases := []TestCase{
+		{"a", Path{{Name: "a"}}},
+		{"a$b", Path{{Name: "a$b"}}},
+		{"about.metric", Path{{Name: "about"}, {Name: "metric"}}},
+		{"tags[0]", Path{{Name: "tags"}, {Index: 0, IsIndex: true}}},
+		{"items[-1].price", Path{{Name: "items"}, {Index: -1, IsIndex: true}, {Name: "price"}}},
+		{"m[1][2]", Path{{Name: "m"}, {Index: 1, IsIndex: true}, {Index: 2, IsIndex: true}}},
+		{`"a.b".c`, Path{{Name: "a.b"}, {Name: "c"}}},
+		{`"say ""hi"""`, Path{{Name: `say "hi"`}}},
+		{"weird key.x", Path{{Name: "weird key"}, {Name: "x"}}},
+	}
+
+	for _, _case := range cases {
+		path, err := ParsePath(_case.text)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", _case.text, err)
+		}
+		if len(path) != len(_case.expected) {
+			t.Fatalf("expected %v for %s, got %v", _case.expected, _case.text, path)
+		}
+		for i := range path {
+			if path[i] != _case.expected[i] {
+				t.Errorf("expected %v for %s, got %v", _case.expected, _case.text, path)
+			}
+		}
+
+		// Paths are printed as they are parsed.
+		if path.String() != _case.text {
+			t.Errorf("expected %s, got %s", _case.text, path.String())
+		}
+	}
+
+	for _, text := range []string{"a[", "a[x]", `"a`} {
+		if _, err := ParsePath(text); err == nil {
+			t.Errorf("expected an error for %s", text)
+		}
+	}
+}
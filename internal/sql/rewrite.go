@@ -0,0 +1,83 @@
+package sql
+
+import "sort"
+
+// ExtractFunction is the table-valued function queried in place of the -> and
+// ->> operators, which SQLite only supports from version 3.38. It takes a JSON
+// value and the path of a value within it, and yields a row holding the value
+// as JSON text in its json column and as an SQL value in its value column.
+const ExtractFunction = "sqj_extract"
+
+// edit replaces the text of a statement between two offsets.
+type edit struct {
+	start int
+	end   int
+	text  string
+}
+
+// Rewrite rewrites a statement into one SQLite can run. Column references with
+// paths, such as about.metric or t.tags[0], are rewritten as references to
+// columns named after their paths, and the -> and ->> operators as queries of
+// ExtractFunction. The statement must be the text stmt was parsed from.
+func Rewrite(query string, stmt *SelectStmt) string {
+	edits := make([]edit, 0, len(stmt.references)+len(stmt.unnamed)+3*len(stmt.arrows))
+
+	qualifiers := qualifiers(stmt)
+	for _, ref := range stmt.references {
+		path, err := ParsePath(ref.value)
+		if err != nil {
+			continue
+		}
+
+		start := ref.start
+		qualifier, path := splitQualifier(path, qualifiers)
+		if qualifier != "" {
+			start = ref.first
+		}
+		if len(path) == 1 && !path[0].IsIndex {
+			continue
+		}
+
+		column := quoteIdentifier(path.String())
+		if qualifier != "" {
+			column = "." + column
+		}
+		edits = append(edits, edit{start: start, end: ref.end, text: column})
+	}
+
+	// Edits inserting text at the same offset are applied in turn, each
+	// ahead of the last, so names are added first to follow the parentheses
+	// closing the arrows of their columns.
+	for _, column := range stmt.unnamed {
+		name := " AS " + quoteIdentifier(query[column.start:column.end])
+		edits = append(edits, edit{start: column.end, end: column.end, text: name})
+	}
+
+	// a -> b is rewritten as (SELECT json FROM sqj_extract(a, b)).
+	for _, arrow := range stmt.arrows {
+		column := "json"
+		if arrow.token == ARROW2 {
+			column = "value"
+		}
+		edits = append(edits,
+			edit{start: arrow.start, end: arrow.start, text: "(SELECT " + column + " FROM " + ExtractFunction + "("},
+			edit{start: arrow.operator, end: arrow.operator + len(arrow.token.String()), text: ","},
+			edit{start: arrow.end, end: arrow.end, text: "))"},
+		)
+	}
+
+	// Edits are applied from the end of the statement, so the offsets of
+	// those still to be applied are unchanged. Where an insertion and a
+	// replacement start together, the insertion is applied last so that it
+	// precedes the replacement.
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].start != edits[j].start {
+			return edits[i].start > edits[j].start
+		}
+		return edits[i].end > edits[j].end
+	})
+	for _, edit := range edits {
+		query = query[:edit.start] + edit.text + query[edit.end:]
+	}
+	return query
+}
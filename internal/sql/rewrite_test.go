@@ -0,0 +1,36 @@
+package sql
+
+import (
+	"testing"
+)
+
+func TestRewrite(t *testing.T) {
+	type TestCase struct {
+		statement string
+		expected  string
+	}
+	cases := []TestCase{
+		{"SELECT a, a$b FROM [];", "SELECT a, a$b FROM [];"},
+		{"SELECT about.metric FROM [];", `SELECT "about.metric" FROM [];`},
+		{"SELECT tags[0], items[-1].price FROM [];", `SELECT "tags[0]", "items[-1].price" FROM [];`},
+		{`SELECT "weird key".x, "a.b" FROM [];`, `SELECT "weird key.x", "a.b" FROM [];`},
+		{"SELECT p.about.metric, p.id FROM [] AS p;", `SELECT p."about.metric", p.id FROM [] AS p;`},
+		{"SELECT content.word FROM content;", "SELECT content.word FROM content;"},
+		{"SELECT i.value FROM [] AS o, each(o.tags[0]) AS i;", `SELECT i.value FROM [] AS o, each(o."tags[0]") AS i;`},
+		{"SELECT a FROM [] WHERE b . c = 'd.e';", `SELECT a FROM [] WHERE "b.c" = 'd.e';`},
+		{"SELECT about.metric + 1, tags[0] AS t FROM [];", `SELECT "about.metric" + 1 AS "about.metric + 1", "tags[0]" AS t FROM [];`},
+		{"SELECT a->'b' FROM [];", `SELECT (SELECT json FROM sqj_extract(a,'b')) AS "a->'b'" FROM [];`},
+		{"SELECT a->>'$.b' AS b FROM [] WHERE c->>0 > 1;",
+			`SELECT (SELECT value FROM sqj_extract(a,'$.b')) AS b FROM [] WHERE (SELECT value FROM sqj_extract(c,0)) > 1;`},
+		{"SELECT p.a.b -> 'c' ->> 'd' FROM [] AS p;",
+			`SELECT (SELECT value FROM sqj_extract((SELECT json FROM sqj_extract(p."a.b" , 'c')) , 'd')) AS "p.a.b -> 'c' ->> 'd'" FROM [] AS p;`},
+		{"SELECT count(*) FROM [] GROUP BY a->>b;", "SELECT count(*) FROM [] GROUP BY (SELECT value FROM sqj_extract(a,b));"},
+	}
+
+	for _, _case := range cases {
+		stmt := parseStatement(_case.statement)
+		if query := Rewrite(_case.statement, &stmt); query != _case.expected {
+			t.Errorf("expected %s, got %s", _case.expected, query)
+		}
+	}
+}
@@ -14,33 +14,37 @@ const (
 	EOF
 
 	// operators
-	MINUS  // -
-	LP     // (
-	RP     // )
-	SEMI   // ;
-	PLUS   // +
-	STAR   // *
-	SLASH  // /
-	REM    // %
-	EQ     // =
-	LE     // <=
-	LSHIFT // <<
-	LT     // <
-	GE     // >=
-	RSHIFT // >>
-	GT     // >
-	NE     // !=
-	COMMA  // ,
-	BITAND // &
-	BITNOT // !
-	BITOR  // |
-	CONCAT // ||
-	DOT    // .
+	MINUS    // -
+	LP       // (
+	RP       // )
+	SEMI     // ;
+	PLUS     // +
+	STAR     // *
+	SLASH    // /
+	REM      // %
+	EQ       // =
+	LE       // <=
+	LSHIFT   // <<
+	LT       // <
+	GE       // >=
+	RSHIFT   // >>
+	GT       // >
+	NE       // !=
+	COMMA    // ,
+	BITAND   // &
+	BITNOT   // !
+	BITOR    // |
+	CONCAT   // ||
+	ARROW    // ->
+	ARROW2   // ->>
+	DOT      // .
+	LBRACKET // [
+	RBRACKET // ]
 
 	// literals
 	IDENTIFIER      // Table, column name, alias etc...
 	NUMERIC_LITERAL // Either INTEGER_LITERAL or FLOAT_LITERAL
-	STRING_LITERAL  // TODO: Handle string literals.
+	STRING_LITERAL
 
 	// keywords
 	ABORT
@@ -195,28 +199,32 @@ var tokens = [...]string{
 	EOF:     "EOF",
 
 	// operators
-	MINUS:  "-",
-	LP:     "(",
-	RP:     ")",
-	SEMI:   ";",
-	PLUS:   "+",
-	STAR:   "*",
-	SLASH:  "/",
-	REM:    "%",
-	EQ:     "=",
-	LE:     "<=",
-	LSHIFT: "<<",
-	LT:     "<",
-	GE:     ">=",
-	RSHIFT: ">>",
-	GT:     ">",
-	NE:     "!=",
-	COMMA:  ",",
-	BITAND: "&",
-	BITNOT: "!",
-	BITOR:  "|",
-	CONCAT: "||",
-	DOT:    ".",
+	MINUS:    "-",
+	LP:       "(",
+	RP:       ")",
+	SEMI:     ";",
+	PLUS:     "+",
+	STAR:     "*",
+	SLASH:    "/",
+	REM:      "%",
+	EQ:       "=",
+	LE:       "<=",
+	LSHIFT:   "<<",
+	LT:       "<",
+	GE:       ">=",
+	RSHIFT:   ">>",
+	GT:       ">",
+	NE:       "!=",
+	COMMA:    ",",
+	BITAND:   "&",
+	BITNOT:   "!",
+	BITOR:    "|",
+	CONCAT:   "||",
+	ARROW:    "->",
+	ARROW2:   "->>",
+	DOT:      ".",
+	LBRACKET: "[",
+	RBRACKET: "]",
 
 	// literals
 	IDENTIFIER:      "IDENTIFIER",
@@ -375,26 +383,43 @@ func (t Token) String() string {
 	return tokens[t]
 }
 
+// Position describes a location in the statement source text.
+type Position struct {
+	Offset int // Byte offset, counting from 0.
+	Line   int // Line number, counting from 1.
+	Column int // Column number in characters, counting from 1.
+}
+
 // Scanner represents a type that scans tokens.
 type Scanner struct {
 	input  []byte // Statement source text.
 	cursor int    // Current position in `input`.
 	char   rune   // Current character, -1 for EOF.
+
+	pos      Position // Position of the current character.
+	tokenPos Position // Position of the most recently scanned token.
 }
 
 // NewScanner creates a new scanner from a statement.
 func NewScanner(statement []byte) *Scanner {
 	scanner := &Scanner{
 		input: statement,
+		pos:   Position{Line: 1},
 	}
 	scanner.next()
 
 	return scanner
 }
 
+// Position returns the position of the most recently scanned token.
+func (s *Scanner) Position() Position {
+	return s.tokenPos
+}
+
 // ScanToken scans the next token, returning the token and its value (if applicable).
 func (s *Scanner) ScanToken() (Token, string) {
 	s.skipWhitespace()
+	s.tokenPos = s.pos
 
 	// Greedily match identifiers, keywords and literals.
 	switch c := s.char; {
@@ -414,22 +439,30 @@ func (s *Scanner) ScanToken() (Token, string) {
 		return IDENTIFIER, value
 	case isDigit(c) || c == '.' && isDigit(s.peek()):
 		value := string(c)
-		for s.next(); isLetter(s.char) || isDigit(s.char) || s.char == '.' || s.char == '-'; s.next() {
+		for s.next(); isLetter(s.char) || isDigit(s.char) || s.char == '.' || isExponentSign(value, s.char); s.next() {
 			value += string(s.char)
 		}
 		return NUMERIC_LITERAL, value
-	case c == '\'' || c == '"':
+	case c == '[' && s.peek() == ']':
+		// The top-level table.
 		s.next()
-		value := ""
-		for {
-			if s.cursor >= len(s.input)-1 || s.char == '\'' || s.char == '"' {
-				s.next()
-				break
-			}
-			value += string(s.char)
-			s.next()
+		s.next()
+		return IDENTIFIER, "[]"
+	case c == '\'' || c == '"':
+		// As in SQLite, double quoted strings are identifiers where the syntax
+		// needs one, which is left to the parser.
+		value, ok := s.scanQuoted()
+		if !ok {
+			return INVALID, value
 		}
 		return STRING_LITERAL, value
+	case c == '`':
+		// Quoted identifiers are never keywords.
+		value, ok := s.scanQuoted()
+		if !ok {
+			return INVALID, value
+		}
+		return IDENTIFIER, value
 	}
 
 	// Match all other tokens.
@@ -438,8 +471,6 @@ func (s *Scanner) ScanToken() (Token, string) {
 	var token Token
 
 	switch c {
-	case '-':
-		token = MINUS
 	case '(':
 		token = LP
 	case ')':
@@ -462,6 +493,10 @@ func (s *Scanner) ScanToken() (Token, string) {
 		token = BITNOT
 	case '.':
 		token = DOT
+	case '[':
+		token = LBRACKET
+	case ']':
+		token = RBRACKET
 	default:
 		peek := s.char
 		switch c {
@@ -472,6 +507,17 @@ func (s *Scanner) ScanToken() (Token, string) {
 			} else {
 				token = EQ
 			}
+		case '-':
+			// Might either be -, -> or ->>.
+			if peek == '>' {
+				token = ARROW
+				if s.next(); s.char == '>' {
+					token = ARROW2
+					s.next()
+				}
+			} else {
+				token = MINUS
+			}
 		case '<':
 			// Might either be <=, <>, << or <.
 			if peek == '=' {
@@ -514,16 +560,54 @@ func (s *Scanner) ScanToken() (Token, string) {
 				token = BITOR
 			}
 		default:
-			token = INVALID
+			return INVALID, string(c)
 		}
 	}
 
 	return token, ""
 }
 
+// isExponentSign returns true if a character is the sign of the exponent of a
+// numeric literal, following value, rather than an operator such as the - of
+// 1-2 or 0->'a'.
+func isExponentSign(value string, char rune) bool {
+	if char != '-' && char != '+' || strings.HasPrefix(strings.ToLower(value), "0x") {
+		return false
+	}
+	return strings.HasSuffix(value, "e") || strings.HasSuffix(value, "E")
+}
+
+// scanQuoted scans a string literal or quoted identifier, returning its value
+// without the quotes. A quote character is escaped by repeating it. Returns the
+// rest of the input and false if the closing quote is missing.
+func (s *Scanner) scanQuoted() (string, bool) {
+	quote := s.char
+	start := s.pos.Offset
+
+	var value strings.Builder
+	for s.next(); ; s.next() {
+		if s.char == 0 && s.cursor >= len(s.input) {
+			return string(s.input[start:]), false
+		}
+		if s.char == quote {
+			if s.next(); s.char != quote {
+				return value.String(), true
+			}
+		}
+		value.WriteRune(s.char)
+	}
+}
+
 // next reads the next character from the input, or sets `s.char = -1`.
 // TODO: Actually handle unicode.
 func (s *Scanner) next() {
+	if s.char == '\n' {
+		s.pos.Line++
+		s.pos.Column = 0
+	}
+	s.pos.Offset = s.cursor
+	s.pos.Column++
+
 	if s.cursor < len(s.input) {
 		if cur := rune(s.input[s.cursor]); cur == utf8.RuneError {
 			panic("malformed input")
@@ -570,7 +654,7 @@ func isLetter(char rune) bool {
 	}
 
 	switch char {
-	case '_', '[', ']', '$':
+	case '_', '$':
 		return true
 	default:
 		return false
@@ -75,6 +75,21 @@ func TestIdentifiers(t *testing.T) {
 		{"_test_table;", []TokenValuePair{{IDENTIFIER, "_test_table"}, {SEMI, ""}}},
 		{"_t1e2s3t_4t5a6l7e;", []TokenValuePair{{IDENTIFIER, "_t1e2s3t_4t5a6l7e"}, {SEMI, ""}}},
 		{"test$;", []TokenValuePair{{IDENTIFIER, "test$"}, {SEMI, ""}}},
+		{"`select` `a ``b`` 'c'`;", []TokenValuePair{
+			{IDENTIFIER, "select"},
+			{IDENTIFIER, "a `b` 'c'"},
+			{SEMI, ""},
+		}},
+		{"items[-1].price;", []TokenValuePair{
+			{IDENTIFIER, "items"},
+			{LBRACKET, ""},
+			{MINUS, ""},
+			{NUMERIC_LITERAL, "1"},
+			{RBRACKET, ""},
+			{DOT, ""},
+			{IDENTIFIER, "price"},
+			{SEMI, ""},
+		}},
 		{"test$table;", []TokenValuePair{{IDENTIFIER, "test$table"}, {SEMI, ""}}},
 		{"test_database.test_table.test_column;",
 			[]TokenValuePair{
@@ -108,6 +123,11 @@ func TestIdentifiers(t *testing.T) {
 func TestStringLiterals(t *testing.T) {
 	var cases = []TestCase{
 		{"\"hello, world\";", []TokenValuePair{{STRING_LITERAL, "hello, world"}, {SEMI, ""}}},
+		{"'hello, world';", []TokenValuePair{{STRING_LITERAL, "hello, world"}, {SEMI, ""}}},
+		{"'hello, world'", []TokenValuePair{{STRING_LITERAL, "hello, world"}}},
+		{"'it''s \"quoted\"'", []TokenValuePair{{STRING_LITERAL, "it's \"quoted\""}}},
+		{"'';", []TokenValuePair{{STRING_LITERAL, ""}, {SEMI, ""}}},
+		{"'unterminated", []TokenValuePair{}},
 	}
 
 	for _, _case := range cases {
@@ -123,6 +143,9 @@ func TestRealNumbers(t *testing.T) {
 		//{"4e2;", []TokenValuePair{{NUMERIC_LITERAL, "4e2"}, {SEMI, ""}}},
 		//{"123.456e-78;", []TokenValuePair{{NUMERIC_LITERAL, "123.456e-78"}, {SEMI, ""}}},
 		{".1E2;", []TokenValuePair{{NUMERIC_LITERAL, ".1E2"}, {SEMI, ""}}},
+		{"1.5e-3+2;", []TokenValuePair{{NUMERIC_LITERAL, "1.5e-3"}, {PLUS, ""}, {NUMERIC_LITERAL, "2"}, {SEMI, ""}}},
+		{"1-2;", []TokenValuePair{{NUMERIC_LITERAL, "1"}, {MINUS, ""}, {NUMERIC_LITERAL, "2"}, {SEMI, ""}}},
+		{"0->>1;", []TokenValuePair{{NUMERIC_LITERAL, "0"}, {ARROW2, ""}, {NUMERIC_LITERAL, "1"}, {SEMI, ""}}},
 	}
 
 	for _, _case := range cases {
@@ -339,7 +362,13 @@ func TestSymbols(t *testing.T) {
 		{"|;", []TokenValuePair{{BITOR, ""}, {SEMI, ""}}},
 		{"!;", []TokenValuePair{{BITNOT, ""}, {SEMI, ""}}},
 		{"||;", []TokenValuePair{{CONCAT, ""}, {SEMI, ""}}},
+		{"->;", []TokenValuePair{{ARROW, ""}, {SEMI, ""}}},
+		{"->>;", []TokenValuePair{{ARROW2, ""}, {SEMI, ""}}},
+		{"->>>;", []TokenValuePair{{ARROW2, ""}, {GT, ""}, {SEMI, ""}}},
+		{"- >;", []TokenValuePair{{MINUS, ""}, {GT, ""}, {SEMI, ""}}},
 		{".;", []TokenValuePair{{DOT, ""}, {SEMI, ""}}},
+		{"[;", []TokenValuePair{{LBRACKET, ""}, {SEMI, ""}}},
+		{"];", []TokenValuePair{{RBRACKET, ""}, {SEMI, ""}}},
 	}
 
 	for _, _case := range cases {
@@ -348,3 +377,24 @@ func TestSymbols(t *testing.T) {
 		checkEquality(t, tokens, _case.expected)
 	}
 }
+
+func TestPositions(t *testing.T) {
+	statement := "SELECT a,\n\tβ FROM [];"
+	expected := []Position{
+		{Offset: 0, Line: 1, Column: 1},   // SELECT
+		{Offset: 7, Line: 1, Column: 8},   // a
+		{Offset: 8, Line: 1, Column: 9},   // ,
+		{Offset: 11, Line: 2, Column: 2},  // β
+		{Offset: 14, Line: 2, Column: 4},  // FROM
+		{Offset: 19, Line: 2, Column: 9},  // []
+		{Offset: 21, Line: 2, Column: 11}, // ;
+	}
+
+	scanner := NewScanner([]byte(statement))
+	for i := 0; i < len(expected); i++ {
+		scanner.ScanToken()
+		if scanner.Position() != expected[i] {
+			t.Fatalf("unexpected position: got %+v, expected %+v", scanner.Position(), expected[i])
+		}
+	}
+}
@@ -1,56 +1,111 @@
 package sql
 
-import (
-	"github.com/progbits/sqjson/internal/util"
-	"strings"
-)
+import "strings"
 
 type SqlSchema struct {
-	Columns          [][]string
-	CreateTableStmts []string
+	Columns [][]string
+
+	// Star records the tables whose columns are selected with '*', and so
+	// must be discovered from the data.
+	Star []bool
+
+	// Paths of the columns referenced with a path, such as about.metric, by
+	// column name. Names are the canonical text of the path.
+	Paths map[string]Path
+
+	// Columns referenced through the aliases of table-valued functions, such
+	// as sku in i.sku or value.sku in i.value.sku.
+	FunctionColumns []string
 }
 
-// schemaFromStmt builds a collection of 'CREATE TABLE ...' statements from a
-// SQL AST.
+// schemaFromStmt collects the columns referenced for each table in a SQL AST.
 func SchemasFromStmt(stmt *SelectStmt) SqlSchema {
 	columns := ExtractIdentifiers(stmt, Column)
 	tables := ExtractIdentifiers(stmt, Table)
+	qualifiers := qualifiers(stmt)
+	functions := make(map[string]bool)
+	for _, alias := range ExtractIdentifiers(stmt, Function) {
+		functions[strings.ToLower(alias)] = true
+	}
 
 	// This is currently a bit horrible, we should really be returning columns
 	// segregated by table.
 	orderedColumns := make([][]string, 0)
-	createTableStmts := make([]string, 0)
+	star := make([]bool, len(tables))
+	paths := make(map[string]Path)
 	for i := 0; i < len(tables); i++ {
 		tableColumns := make([]string, 0)
 		unique := make(map[string]bool)
-		stmt := "CREATE TABLE IF NOT EXISTS " + tables[i] + "("
 		for j := 0; j < len(columns); j++ {
-			parts := strings.Split(columns[j], ".")
-			if len(tables) > 1 && len(parts) > 1 && parts[0] != tables[i] {
+			path, err := ParsePath(columns[j])
+			if err != nil || len(path) == 0 {
 				continue
 			}
 
-			columnName := parts[len(parts)-1]
-			if _, ok := unique[columnName]; ok {
+			qualifier, path := splitQualifier(path, qualifiers)
+			if len(tables) > 1 && qualifier != "" && qualifier != tables[i] && !isAlias(qualifier, tables) {
 				continue
 			}
 
-			stmt += util.EscapeString(columnName)
-			if j < len(columns)-1 {
-				stmt += ","
+			columnName := path[0].Name
+			if len(path) > 1 || path[0].IsIndex {
+				columnName = path.String()
+				paths[columnName] = path
+			}
+			if columnName == "*" {
+				star[i] = true
+				continue
+			}
+			if _, ok := unique[columnName]; ok {
+				continue
 			}
+
 			tableColumns = append(tableColumns, columnName)
 			unique[columnName] = true
 		}
-		stmt = strings.TrimRight(stmt, ",")
-		stmt += ");"
 
 		orderedColumns = append(orderedColumns, tableColumns)
-		createTableStmts = append(createTableStmts, stmt)
+	}
+
+	functionColumns := make([]string, 0)
+	unique := make(map[string]bool)
+	for _, column := range columns {
+		path, err := ParsePath(column)
+		if err != nil || len(path) == 0 {
+			continue
+		}
+
+		qualifier, path := splitQualifier(path, qualifiers)
+		if !functions[strings.ToLower(qualifier)] || path[0].Name == "*" {
+			continue
+		}
+
+		columnName := path[0].Name
+		if len(path) > 1 || path[0].IsIndex {
+			columnName = path.String()
+			paths[columnName] = path
+		}
+		if !unique[columnName] {
+			functionColumns = append(functionColumns, columnName)
+			unique[columnName] = true
+		}
 	}
 
 	return SqlSchema{
-		Columns:          orderedColumns,
-		CreateTableStmts: createTableStmts,
+		Columns:         orderedColumns,
+		Star:            star,
+		Paths:           paths,
+		FunctionColumns: functionColumns,
+	}
+}
+
+// isAlias returns true if a column qualifier does not name one of the tables,
+// so may be an alias for any of them.
+func isAlias(qualifier string, tables []string) bool {
+	for _, table := range tables {
+		if qualifier == table {
+			return false
+		}
 	}
+	return true
 }
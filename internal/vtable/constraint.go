@@ -0,0 +1,180 @@
+package vtable
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// constraint is a comparison between a column and a value, claimed from SQLite
+// in BestIndex, which rows must satisfy.
+//
+// SQLite does not check claimed constraints itself, so they must be evaluated
+// exactly as SQLite would.
+type constraint struct {
+	column int
+	op     sqlite3.Op
+	value  interface{}
+}
+
+// plan is the columns and operators of the constraints claimed by a call to
+// BestIndex, in the order their values are passed to Filter.
+//
+// Plans are kept by the table and identified by idxNum, as go-sqlite3 frees
+// idxStr before SQLite passes it to Filter.
+type plan []constraint
+
+// bind pairs the constraints of a plan with their values.
+func (p plan) bind(vals []interface{}) ([]constraint, error) {
+	if len(p) != len(vals) {
+		return nil, fmt.Errorf("expected %d constraint values, got %d", len(p), len(vals))
+	}
+
+	constraints := make([]constraint, len(p))
+	for i := range p {
+		constraints[i] = p[i]
+		constraints[i].value = vals[i]
+
+		// NULL values are passed as a nil byte slice.
+		if value, ok := vals[i].([]byte); ok && value == nil {
+			constraints[i].value = nil
+		}
+	}
+	return constraints, nil
+}
+
+// matches returns true if a column value satisfies the constraint. Comparisons
+// involving NULL are never satisfied.
+func (c *constraint) matches(value interface{}) bool {
+	if value == nil || c.value == nil {
+		return false
+	}
+
+	cmp := compare(value, c.value)
+	switch c.op {
+	case sqlite3.OpEQ:
+		return cmp == 0
+	case sqlite3.OpGT:
+		return cmp > 0
+	case sqlite3.OpGE:
+		return cmp >= 0
+	case sqlite3.OpLT:
+		return cmp < 0
+	case sqlite3.OpLE:
+		return cmp <= 0
+	default:
+		return false
+	}
+}
+
+// storageClass orders values of different types as SQLite does. NULL sorts
+// first, followed by numbers, text and blobs.
+func storageClass(value interface{}) int {
+	switch value.(type) {
+	case nil:
+		return 0
+	case int64, float64:
+		return 1
+	case string:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// compare compares two non-NULL values as SQLite does for operands with no
+// affinity and the BINARY collation, returning -1, 0 or 1.
+//
+// https://www.sqlite.org/datatype3.html#comparisons
+func compare(a, b interface{}) int {
+	if classA, classB := storageClass(a), storageClass(b); classA != classB {
+		if classA < classB {
+			return -1
+		}
+		return 1
+	}
+
+	switch a := a.(type) {
+	case int64:
+		switch b := b.(type) {
+		case int64:
+			return compareInts(a, b)
+		case float64:
+			return compareIntFloat(a, b)
+		}
+	case float64:
+		switch b := b.(type) {
+		case int64:
+			return -compareIntFloat(b, a)
+		case float64:
+			if a < b {
+				return -1
+			} else if a > b {
+				return 1
+			}
+			return 0
+		}
+	case string:
+		return strings.Compare(a, b.(string))
+	case []byte:
+		return strings.Compare(string(a), string(b.([]byte)))
+	}
+	panic("unexpected value\n")
+}
+
+func compareInts(a, b int64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+// compareIntFloat compares an integer and a float exactly, without converting
+// the integer to a float first.
+func compareIntFloat(i int64, r float64) int {
+	if math.IsNaN(r) {
+		return 1
+	}
+	if r < -9223372036854775808.0 {
+		return 1
+	}
+	if r >= 9223372036854775808.0 {
+		return -1
+	}
+	if cmp := compareInts(i, int64(r)); cmp != 0 {
+		return cmp
+	}
+
+	// The float is within one of the integer, so compare its fractional part.
+	if s := float64(i); s < r {
+		return -1
+	} else if s > r {
+		return 1
+	}
+	return 0
+}
+
+// indexKey returns a key for a value such that values SQLite considers equal
+// have equal keys. NULL values have no key, as they are never equal.
+func indexKey(value interface{}) (string, bool) {
+	switch value := value.(type) {
+	case int64:
+		return "n" + strconv.FormatInt(value, 10), true
+	case float64:
+		if value == math.Trunc(value) && value >= -9223372036854775808.0 && value < 9223372036854775808.0 {
+			return "n" + strconv.FormatInt(int64(value), 10), true
+		}
+		return "n" + strconv.FormatFloat(value, 'g', -1, 64), true
+	case string:
+		return "t" + value, true
+	case []byte:
+		return "b" + string(value), true
+	default:
+		return "", false
+	}
+}
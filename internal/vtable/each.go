@@ -0,0 +1,293 @@
+package vtable
+
+import (
+	"fmt"
+	"github.com/progbits/sqjson/internal/json"
+	sqlj "github.com/progbits/sqjson/internal/sql"
+	"github.com/progbits/sqjson/internal/util"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Names of the table-valued functions implemented by eachModule.
+var eachFunctions = []string{"each", "unnest"}
+
+// Columns of the each table. The hidden json and path columns are the
+// arguments of the table-valued function, and are followed by a hidden column
+// per member referenced through an alias of the function.
+const (
+	eachKey = iota
+	eachValue
+	eachType
+	eachIndex
+	eachJSON
+	eachPath
+	eachMembers
+)
+
+// Names of the columns of the each table, up to its member columns.
+var eachColumns = []string{"key", "value", "type", "index", "json", "path"}
+
+// eachModule implements the each and unnest table-valued functions, which take
+// a JSON value, and optionally the path of a member within it, and yield a row
+// per element of an array or per member of an object:
+//
+//	SELECT o.id, i.value FROM [] AS o, each(o.items) AS i
+//
+// Other values yield a single row. Members of the values are also columns of
+// the table, so i.sku or i.value.sku is the sku member of the value of i.
+type eachModule struct {
+	types   []valueTypes // Types produced by the key, value and member columns.
+	last    lastValues   // Last values produced by the columns.
+	first   int          // Index of the first of types in ClientData.types.
+	members []string     // Members referenced through aliases of the function.
+	paths   map[string]sqlj.Path
+}
+
+// isEachColumn returns true if a column name refers to one of the columns of
+// the each table, rather than a member of its values.
+func isEachColumn(column string) bool {
+	for _, name := range eachColumns {
+		if strings.EqualFold(column, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *eachModule) EponymousOnlyModule() {}
+
+func (m *eachModule) Create(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab, error) {
+	stmt := fmt.Sprintf(`CREATE TABLE x(key %s, value %s, type, "index", json HIDDEN, path HIDDEN`,
+		fmt.Sprintf(columnType, m.first+eachKey), fmt.Sprintf(columnType, m.first+eachValue))
+	for i, member := range m.members {
+		stmt += ", " + util.EscapeString(member) + " HIDDEN " + fmt.Sprintf(columnType, m.first+eachValue+1+i)
+	}
+	if err := c.DeclareVTab(stmt + ");"); err != nil {
+		return nil, err
+	}
+
+	// Members are found relative to the value of each row.
+	paths := make([]sqlj.Path, len(m.members))
+	for i, member := range m.members {
+		path, ok := m.paths[member]
+		if !ok {
+			path = sqlj.Path{{Name: member}}
+		}
+		if len(path) > 1 && !path[0].IsIndex && strings.EqualFold(path[0].Name, eachColumns[eachValue]) {
+			path = path[1:]
+		}
+		paths[i] = path
+	}
+	return &eachTable{types: m.types, last: m.last, members: paths}, nil
+}
+
+func (m *eachModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab, error) {
+	return m.Create(c, args)
+}
+
+func (m *eachModule) DestroyModule() {}
+
+type eachTable struct {
+	types   []valueTypes
+	last    lastValues
+	members []sqlj.Path // Paths of the member columns within each value.
+
+	// Arguments claimed by each call to BestIndex, identified by idxNum.
+	plans []plan
+}
+
+func (v *eachTable) Open() (sqlite3.VTabCursor, error) {
+	return &eachCursor{eachTable: v}, nil
+}
+
+// BestIndex claims equality constraints on the json and path columns, being
+// the arguments of the function.
+func (v *eachTable) BestIndex(csts []sqlite3.InfoConstraint, ob []sqlite3.InfoOrderBy) (*sqlite3.IndexResult, error) {
+	return argumentIndex(&v.plans, csts, eachJSON), nil
+}
+
+// argumentIndex claims equality constraints on the columns of a table-valued
+// function from first onwards, being its arguments, adding a plan for them to
+// plans. Plans without the first argument yield no rows, so are made too
+// costly to be chosen where SQLite has an alternative.
+func argumentIndex(plans *[]plan, csts []sqlite3.InfoConstraint, first int) *sqlite3.IndexResult {
+	used := make([]bool, len(csts))
+	claimed := plan{}
+	seen := make(map[int]bool)
+	for i, cst := range csts {
+		if !cst.Usable || cst.Op != sqlite3.OpEQ || cst.Column < first || seen[cst.Column] {
+			continue
+		}
+		used[i] = true
+		seen[cst.Column] = true
+		claimed = append(claimed, constraint{column: cst.Column, op: cst.Op})
+	}
+
+	cost := float64(fullScanCost)
+	if !seen[first] {
+		cost *= fullScanCost
+	}
+
+	*plans = append(*plans, claimed)
+	return &sqlite3.IndexResult{
+		Used:          used,
+		IdxNum:        len(*plans) - 1,
+		EstimatedCost: cost,
+	}
+}
+
+func (v *eachTable) Disconnect() error { return nil }
+func (v *eachTable) Destroy() error    { return nil }
+
+type eachCursor struct {
+	*eachTable
+	node *json.ASTNode // Value whose elements or members are the rows.
+	rows []*json.ASTNode
+	i    int // Index of the current row.
+}
+
+// Filter parses the arguments of the function and finds its rows.
+func (vc *eachCursor) Filter(idxNum int, idxStr string, vals []interface{}) error {
+	if idxNum < 0 || idxNum >= len(vc.plans) {
+		return fmt.Errorf("unknown index %d", idxNum)
+	}
+	args, err := vc.plans[idxNum].bind(vals)
+	if err != nil {
+		return err
+	}
+
+	vc.node = nil
+	vc.rows = nil
+	vc.i = 0
+
+	var value, path interface{}
+	for _, arg := range args {
+		switch arg.column {
+		case eachJSON:
+			value = arg.value
+		case eachPath:
+			path = arg.value
+		}
+	}
+
+	node, err := argNode(value)
+	if err != nil || node == nil {
+		return err
+	}
+	if path != nil {
+		text, ok := path.(string)
+		if !ok {
+			return fmt.Errorf("expected a path, got %v", path)
+		}
+		elements, err := sqlj.ParsePath(text)
+		if err != nil {
+			return err
+		}
+		if node = findPath(node, elements); node == nil {
+			return nil
+		}
+	}
+
+	vc.node = node
+	switch node.Value {
+	case json.JSON_VALUE_ARRAY:
+		vc.rows = node.Values
+	case json.JSON_VALUE_OBJECT:
+		vc.rows = node.Members
+	default:
+		vc.rows = []*json.ASTNode{node}
+	}
+	return nil
+}
+
+// argNode converts a value passed to the function to an AST. Objects and arrays
+// are passed to SQLite as JSON text, so text holding an object or array is
+// parsed as JSON. Other text is a string.
+func argNode(value interface{}) (*json.ASTNode, error) {
+	text := ""
+	switch value := value.(type) {
+	case nil:
+		return nil, nil
+	case int64:
+		return &json.ASTNode{
+			Value:   json.JSON_VALUE_NUMBER,
+			Number:  float64(value),
+			Literal: strconv.FormatInt(value, 10),
+		}, nil
+	case float64:
+		return &json.ASTNode{Value: json.JSON_VALUE_NUMBER, Number: value}, nil
+	case string:
+		text = value
+	case []byte:
+		text = string(value)
+	}
+
+	if trimmed := strings.TrimSpace(text); !strings.HasPrefix(trimmed, "[") && !strings.HasPrefix(trimmed, "{") {
+		return &json.ASTNode{Value: json.JSON_VALUE_STRING, String: text}, nil
+	}
+	parser := json.NewParser(json.NewTokenizer(strings.NewReader(text)))
+	if err := parser.Parse(); err != nil {
+		return nil, fmt.Errorf("malformed JSON argument %q", text)
+	}
+	return &parser.Ast, nil
+}
+
+func (vc *eachCursor) Column(c *sqlite3.SQLiteContext, col int) error {
+	row := vc.rows[vc.i]
+	switch col {
+	case eachKey:
+		switch vc.node.Value {
+		case json.JSON_VALUE_ARRAY:
+			vc.types[eachKey] |= numberValues
+			c.ResultInt64(int64(vc.i))
+		case json.JSON_VALUE_OBJECT:
+			vc.types[eachKey] |= stringValues
+			result(c, row.Name)
+		default:
+			c.ResultNull()
+		}
+	case eachValue:
+		vc.types[eachValue] |= nodeTypes(row)
+		result(c, vc.last.set(eachValue, row))
+	case eachType:
+		c.ResultText(json.TypeName(row.Value))
+	case eachIndex:
+		c.ResultInt64(int64(vc.i))
+	default:
+		if col < eachMembers || col >= eachMembers+len(vc.members) {
+			c.ResultNull()
+			return nil
+		}
+
+		// Types of the member columns follow those of the value column.
+		i := eachValue + 1 + col - eachMembers
+		node := findPath(row, vc.members[col-eachMembers])
+		vc.types[i] |= nodeTypes(node)
+		result(c, vc.last.set(i, node))
+	}
+	return nil
+}
+
+func (vc *eachCursor) Next() error {
+	vc.i++
+	return nil
+}
+
+func (vc *eachCursor) EOF() bool {
+	if vc.i >= len(vc.rows) {
+		vc.last.clear()
+		return true
+	}
+	return false
+}
+
+func (vc *eachCursor) Rowid() (int64, error) {
+	return int64(vc.i), nil
+}
+
+func (vc *eachCursor) Close() error {
+	return nil
+}
@@ -0,0 +1,227 @@
+package vtable
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/progbits/sqjson/internal/json"
+	sqlj "github.com/progbits/sqjson/internal/sql"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Columns of the extract table. The hidden input and path columns are the
+// arguments of the table-valued function.
+const (
+	extractJSON = iota
+	extractValue
+	extractInput
+	extractPath
+)
+
+// extractModule implements the table-valued function queried in place of the
+// -> and ->> operators, which take a JSON value and the path of a value within
+// it:
+//
+//	SELECT content->'$[0]', content->>'$[0].word' FROM []
+//
+// The function yields a single row holding the value as JSON text and as an
+// SQL value, or no rows if there is no value at the path.
+type extractModule struct {
+	types []valueTypes // Types produced by the json and value columns.
+	last  lastValues   // Last values produced by the columns.
+	first int          // Index of the first of types in ClientData.types.
+}
+
+func (m *extractModule) EponymousOnlyModule() {}
+
+func (m *extractModule) Create(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab, error) {
+	stmt := fmt.Sprintf(`CREATE TABLE x(json %s, value %s, input HIDDEN, path HIDDEN);`,
+		fmt.Sprintf(columnType, m.first+extractJSON), fmt.Sprintf(columnType, m.first+extractValue))
+	if err := c.DeclareVTab(stmt); err != nil {
+		return nil, err
+	}
+	return &extractTable{types: m.types, last: m.last}, nil
+}
+
+func (m *extractModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab, error) {
+	return m.Create(c, args)
+}
+
+func (m *extractModule) DestroyModule() {}
+
+type extractTable struct {
+	types []valueTypes
+	last  lastValues
+
+	// Arguments claimed by each call to BestIndex, identified by idxNum.
+	plans []plan
+}
+
+func (v *extractTable) Open() (sqlite3.VTabCursor, error) {
+	return &extractCursor{extractTable: v}, nil
+}
+
+// BestIndex claims equality constraints on the input and path columns, being
+// the arguments of the function.
+func (v *extractTable) BestIndex(csts []sqlite3.InfoConstraint, ob []sqlite3.InfoOrderBy) (*sqlite3.IndexResult, error) {
+	return argumentIndex(&v.plans, csts, extractInput), nil
+}
+
+func (v *extractTable) Disconnect() error { return nil }
+func (v *extractTable) Destroy() error    { return nil }
+
+type extractCursor struct {
+	*extractTable
+	node *json.ASTNode // Value at the path, if any.
+	eof  bool
+}
+
+// Filter parses the arguments of the function and finds the value at the path.
+func (vc *extractCursor) Filter(idxNum int, idxStr string, vals []interface{}) error {
+	if idxNum < 0 || idxNum >= len(vc.plans) {
+		return fmt.Errorf("unknown index %d", idxNum)
+	}
+	args, err := vc.plans[idxNum].bind(vals)
+	if err != nil {
+		return err
+	}
+
+	vc.node = nil
+	vc.eof = true
+
+	var value, path interface{}
+	for _, arg := range args {
+		switch arg.column {
+		case extractInput:
+			value = arg.value
+		case extractPath:
+			path = arg.value
+		}
+	}
+
+	node, err := argNode(value)
+	if err != nil || node == nil {
+		return err
+	}
+	if path != nil {
+		elements, err := pathArgument(path)
+		if err != nil {
+			return err
+		}
+		if node = findPath(node, elements); node == nil {
+			return nil
+		}
+	}
+
+	vc.node = node
+	vc.eof = false
+	return nil
+}
+
+// pathArgument converts the right operand of -> or ->> to a path. As in SQLite,
+// text starting with '$' is a JSON path, such as $.a[0] or $.a[#-1], other text
+// is the name of an object member and integers are array indexes. Negative
+// indexes count back from the end of the array.
+func pathArgument(value interface{}) (sqlj.Path, error) {
+	switch value := value.(type) {
+	case int64:
+		return sqlj.Path{{Index: int(value), IsIndex: true}}, nil
+	case string:
+		if strings.HasPrefix(value, "$") {
+			return parseJSONPath(value)
+		}
+		return sqlj.Path{{Name: value}}, nil
+	default:
+		return nil, fmt.Errorf("expected a path, got %v", value)
+	}
+}
+
+// parseJSONPath parses a JSON path, being '$' followed by any number of .name,
+// ."name", [N] or [#-N] elements.
+func parseJSONPath(text string) (sqlj.Path, error) {
+	malformed := fmt.Errorf("malformed JSON path %q", text)
+
+	path := make(sqlj.Path, 0)
+	for i := 1; i < len(text); {
+		switch text[i] {
+		case '.':
+			i++
+			if i < len(text) && text[i] == '"' {
+				end := strings.IndexByte(text[i+1:], '"')
+				if end < 0 {
+					return nil, malformed
+				}
+				path = append(path, sqlj.PathElement{Name: text[i+1 : i+1+end]})
+				i += end + 2
+				continue
+			}
+
+			end := strings.IndexAny(text[i:], ".[")
+			if end < 0 {
+				end = len(text) - i
+			}
+			if end == 0 {
+				return nil, malformed
+			}
+			path = append(path, sqlj.PathElement{Name: text[i : i+end]})
+			i += end
+		case '[':
+			end := strings.IndexByte(text[i:], ']')
+			if end < 0 {
+				return nil, malformed
+			}
+			index, sign := text[i+1:i+end], 1
+			if strings.HasPrefix(index, "#-") {
+				index, sign = index[2:], -1
+			}
+			n, err := strconv.Atoi(index)
+			if err != nil || n < 0 {
+				return nil, malformed
+			}
+			path = append(path, sqlj.PathElement{Index: sign * n, IsIndex: true})
+			i += end + 1
+		default:
+			return nil, malformed
+		}
+	}
+	return path, nil
+}
+
+func (vc *extractCursor) Column(c *sqlite3.SQLiteContext, col int) error {
+	switch col {
+	case extractJSON:
+		// The text of any JSON value is converted back to the value.
+		vc.types[extractJSON] |= containerValues
+		buf := bytes.NewBuffer(nil)
+		json.PrettyPrint(buf, vc.node, true)
+		result(c, buf.String())
+	case extractValue:
+		vc.types[extractValue] |= nodeTypes(vc.node)
+		result(c, vc.last.set(extractValue, vc.node))
+	default:
+		c.ResultNull()
+	}
+	return nil
+}
+
+func (vc *extractCursor) Next() error {
+	vc.eof = true
+	return nil
+}
+
+func (vc *extractCursor) EOF() bool {
+	if vc.eof {
+		vc.last.clear()
+	}
+	return vc.eof
+}
+
+func (vc *extractCursor) Rowid() (int64, error) {
+	return 0, nil
+}
+
+func (vc *extractCursor) Close() error {
+	return nil
+}
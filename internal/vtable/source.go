@@ -0,0 +1,122 @@
+package vtable
+
+import (
+	"errors"
+
+	"github.com/progbits/sqjson/internal/json"
+)
+
+// source provides indexed access to the records of a JSON input.
+//
+// Records are pulled from the reader on demand. Unless retain is set, only the
+// most recently read record is kept in memory, so the input can be scanned
+// exactly once.
+type source struct {
+	reader  Reader
+	nth     *int // Only read the nth record of the input, if set.
+	retain  bool
+	records []*json.ASTNode
+	files   []string // File each of records was read from.
+	first   int      // Index of records[0].
+	offset  int      // Index of the first record in the input, when nth is set.
+	eof     bool
+	err     error // First error encountered reading the input.
+
+	selected bool // The nth record has been read.
+}
+
+// next returns the next record from the reader and the file it was read from,
+// or nil once the input is exhausted.
+func (s *source) next() (*json.ASTNode, string, error) {
+	if s.nth == nil {
+		node, err := s.reader.Next()
+		return node, s.reader.File(), err
+	}
+
+	if s.selected {
+		return nil, "", nil
+	}
+	s.selected = true
+	return s.nthRecord(*s.nth)
+}
+
+// nthRecord returns the n'th record of the input, counting from 0, or from -1
+// at the end of the input if n is negative. Only the records required to find
+// it are held in memory. Returns nil if the input has too few records.
+func (s *source) nthRecord(n int) (*json.ASTNode, string, error) {
+	var last []*json.ASTNode
+	var files []string
+	i := 0
+	for ; ; i++ {
+		node, err := s.reader.Next()
+		if err != nil {
+			return nil, "", err
+		}
+		if node == nil {
+			break
+		}
+
+		if i == n {
+			s.offset = i
+			return node, s.reader.File(), nil
+		}
+		if n < 0 {
+			if len(last) == -n {
+				last = last[1:]
+				files = files[1:]
+			}
+			last = append(last, node)
+			files = append(files, s.reader.File())
+		}
+	}
+
+	if n < 0 && len(last) == -n {
+		s.offset = i + n
+		return last[0], files[0], nil
+	}
+	return nil, "", nil
+}
+
+// record returns the i'th record of the input, or nil if the input contains
+// fewer than i+1 records.
+func (s *source) record(i int) (*json.ASTNode, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	for !s.eof && i >= s.first+len(s.records) {
+		node, file, err := s.next()
+		if err != nil {
+			s.err = err
+			return nil, err
+		}
+		if node == nil {
+			s.eof = true
+			break
+		}
+
+		if !s.retain {
+			s.first += len(s.records)
+			s.records = s.records[:0]
+			s.files = s.files[:0]
+		}
+		s.records = append(s.records, node)
+		s.files = append(s.files, file)
+	}
+
+	if i < s.first {
+		s.err = errors.New("input can only be scanned once")
+		return nil, s.err
+	}
+
+	if i >= s.first+len(s.records) {
+		return nil, nil
+	}
+	return s.records[i-s.first], nil
+}
+
+// file returns the file the i'th record of the input was read from. The record
+// must have been read with record.
+func (s *source) file(i int) string {
+	return s.files[i-s.first]
+}
@@ -3,10 +3,11 @@ package vtable
 import (
 	"bytes"
 	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/progbits/sqjson/internal/json"
 	sqlj "github.com/progbits/sqjson/internal/sql"
-	"log"
+	"github.com/progbits/sqjson/internal/util"
 	"strconv"
 	"strings"
 
@@ -17,10 +18,124 @@ import (
 // register their own drivers without stepping on each others toes.
 var Driver = "sqlite_with_extensions"
 
+// columnType is the declared type of virtual table columns. BLOB gives the
+// columns no affinity, so values are compared as they are, and the argument
+// identifies the column so result values can be traced back to it.
+const columnType = "JSON BLOB(%d)"
+
+// valueTypes records the JSON types of the values produced for a column.
+//
+// SQLite has no boolean, object or array values, so booleans are passed to
+// SQLite as integers and objects and arrays as JSON text. Knowing which types a
+// column has produced lets us map result values back to the original types.
+type valueTypes uint8
+
+const (
+	booleanValues valueTypes = 1 << iota
+	numberValues
+	stringValues
+	containerValues
+)
+
+// lastValue is the node most recently produced for a column, and the value
+// passed to SQLite for it.
+type lastValue struct {
+	node  *json.ASTNode
+	value interface{}
+}
+
+// lastValues records the last value produced for each column. SQLite cannot
+// represent every JSON value exactly, such as booleans, 1.50, 1e400 or integers
+// beyond an int64, so a result equal to the last value of its column, being
+// that value passed through unchanged, is printed from its node instead. Only
+// the last value is kept, so memory does not grow with the input.
+//
+// Rows are only returned by a cursor positioned on them, so the last values of
+// a table are cleared once its cursor reaches the end of the table. Results
+// read after that, such as sorted rows, are converted using the types each
+// column has produced.
+type lastValues []lastValue
+
+// set records a node produced for a column, returning the value to pass to
+// SQLite for it.
+func (l lastValues) set(col int, node *json.ASTNode) interface{} {
+	value := sqlValue(node)
+	l[col] = lastValue{node: node, value: value}
+	return value
+}
+
+// clear forgets the last values of the columns.
+func (l lastValues) clear() {
+	for i := range l {
+		l[i] = lastValue{}
+	}
+}
+
+// Hidden columns of each table, following the columns of the table. Hidden
+// columns are not selected by 'SELECT *', but can be referenced by name, and
+// take precedence over members of the same name.
+var hiddenColumns = []string{"_index", "_path", "_parent_index", "_raw", "_file"}
+
+// Name of the column holding rows that are not objects, such as the numbers of
+// [1, 2, 3], as their members are not columns.
+const valueColumn = "value"
+
+// Indexes of each of hiddenColumns.
+const (
+	hiddenIndex       = iota // Index of the row in its array, or of its record in the input.
+	hiddenPath               // JSON pointer of the row within its record.
+	hiddenParentIndex        // Index of the record holding a row of a nested table.
+	hiddenRaw                // The row as compact JSON.
+	hiddenFile               // Path of the file the row was read from.
+)
+
+// Reader reads the records of a JSON input.
+type Reader interface {
+	// Next returns the next record of the input, or nil once the input is
+	// exhausted.
+	Next() (*json.ASTNode, error)
+
+	// File returns the path of the file the last record was read from.
+	File() string
+}
+
+// Input is a JSON input to be queried.
+type Input struct {
+	// Name of the table holding the top-level values of the input, if any.
+	// The top-level values of the first input are also the '[]' table, and
+	// other tables are nested tables of the first input.
+	Name   string
+	Reader Reader
+}
+
 type ClientData struct {
-	JsonAst *json.ASTNode
-	SqlAst  *sqlj.SelectStmt
-	Query   string
+	Inputs []Input
+	SqlAst *sqlj.SelectStmt
+	Query  string
+
+	// Nth selects a single record of the first input to query, counting from
+	// 0, or from -1 at the end of the input. All records are queried if nil.
+	Nth *int
+
+	sources []*source    // Sources of the records of each input.
+	types   []valueTypes // Types produced by each column of each table.
+
+	// Last values produced by each column, indexed as types.
+	last lastValues
+
+	// Paths of the columns referenced with a path, by column name.
+	paths map[string]sqlj.Path
+
+	// Whether constraints can be evaluated by the virtual tables.
+	pushdown bool
+
+	// Whether a plan using constraints may scan a table more than once, which
+	// streamed inputs cannot provide.
+	multiScan bool
+
+	// Whether any record read so far contains each nested table, by table
+	// name. Tables are missing until a record has been read for them.
+	found map[string]bool
 }
 
 type jsonModule struct {
@@ -28,6 +143,10 @@ type jsonModule struct {
 	createTableStmt *string
 	table           *string
 	columns         *[]string
+	types           []valueTypes
+	last            lastValues
+	source          *source
+	path            string
 }
 
 func (m *jsonModule) Create(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab, error) {
@@ -44,6 +163,10 @@ func (m *jsonModule) Create(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab,
 		clientData: m.clientData,
 		table:      *m.table,
 		columns:    *m.columns,
+		types:      m.types,
+		last:       m.last,
+		source:     m.source,
+		path:       m.path,
 	}
 
 	return table, nil
@@ -59,162 +182,488 @@ type jsonTable struct {
 	clientData *ClientData
 	table      string
 	columns    []string
-}
+	types      []valueTypes
+	last       lastValues
+	source     *source
+	path       string // Path of the table node in each record, empty for top-level tables.
 
-func (v *jsonTable) Open() (sqlite3.VTabCursor, error) {
-	queryRootNode := v.clientData.JsonAst
-	var currentNode *json.ASTNode = nil
-	if v.table == "[]" {
-		// Querying top level node.
-		currentNode = v.clientData.JsonAst
-	} else {
-		// Querying a nested member.
-		if v.clientData.JsonAst.Value == json.JSON_VALUE_OBJECT {
-			currentNode = json.FindNode(v.clientData.JsonAst, v.table)
-			queryRootNode = currentNode
-		} else if v.clientData.JsonAst.Value == json.JSON_VALUE_ARRAY {
-			currentNode = json.FindNode(v.clientData.JsonAst.Values[0], v.table)
-		} else {
-			panic("expected an object or an array")
-		}
-	}
-
-	allColumns := sqlj.ExtractIdentifiers(v.clientData.SqlAst, sqlj.Column)
-	var tableColumns []string
-	for _, column := range allColumns {
-		parts := strings.Split(column, ".")
-		if len(parts) > 1 && parts[0] != v.table || len(parts) == 1 {
-			continue
-		} else {
-			tableColumns = append(tableColumns, parts[len(parts)-1])
-		}
-	}
+	// Constraints claimed by each call to BestIndex, identified by idxNum.
+	plans []plan
 
-	if len(tableColumns) == 0 {
-		tableColumns = allColumns
-	}
+	// Indexes of rows by column value, built on first use.
+	indexes map[int]map[string][]position
+}
 
-	if currentNode == nil {
-		panic("unable to locate JSON AST node for table")
-	}
+// position identifies a row by the index of its record and its index within
+// the rows of the record.
+type position struct {
+	x, y int
+}
 
+// row is a row of a table within a record.
+type row struct {
+	node    *json.ASTNode
+	index   int    // Index of the row in its array, or -1.
+	pointer string // JSON pointer of the row within its record.
+}
+
+func (v *jsonTable) Open() (sqlite3.VTabCursor, error) {
 	// Construct a new cursor with the column mappings for the current table.
 	cursor := &jsonCursor{
 		jsonTable: v,
-		current:   currentNode,
-		queryRoot: queryRootNode,
 		columns:   v.columns,
 	}
 	return cursor, nil
 }
 
+// Estimated cost of a full scan of a table.
+const fullScanCost = 1000000
+
+// BestIndex claims equality and range constraints on columns, which are then
+// evaluated by the cursor rather than SQLite. Constraints on streamed inputs
+// are only claimed where the plan scans the table once.
 func (v *jsonTable) BestIndex(csts []sqlite3.InfoConstraint, ob []sqlite3.InfoOrderBy) (*sqlite3.IndexResult, error) {
-	return &sqlite3.IndexResult{Used: make([]bool, len(csts))}, nil
+	used := make([]bool, len(csts))
+	claimed := plan{}
+	cost := float64(fullScanCost)
+	for i, cst := range csts {
+		if !v.clientData.pushdown || !v.source.retain && v.clientData.multiScan || !cst.Usable || cst.Column < 0 || cst.Column >= len(v.columns)+len(hiddenColumns) {
+			continue
+		}
+
+		switch cst.Op {
+		case sqlite3.OpEQ:
+			cost /= 100
+		case sqlite3.OpGT, sqlite3.OpGE, sqlite3.OpLT, sqlite3.OpLE:
+			cost /= 2
+		default:
+			continue
+		}
+		used[i] = true
+		claimed = append(claimed, constraint{column: cst.Column, op: cst.Op})
+	}
+
+	v.plans = append(v.plans, claimed)
+	return &sqlite3.IndexResult{
+		Used:          used,
+		IdxNum:        len(v.plans) - 1,
+		EstimatedCost: cost,
+	}, nil
 }
 
 func (v *jsonTable) Disconnect() error { return nil }
 func (v *jsonTable) Destroy() error    { return nil }
 
+// index returns an index of the rows of the table by the value of a column.
+// Building an index requires the input to be held in memory.
+func (v *jsonTable) index(col int) (map[string][]position, error) {
+	if index, ok := v.indexes[col]; ok {
+		return index, nil
+	}
+
+	index := make(map[string][]position)
+	cursor := &jsonCursor{jsonTable: v, columns: v.columns}
+	cursor.reset(nil)
+	if err := cursor.seek(); err != nil {
+		return nil, err
+	}
+	for !cursor.eof {
+		if key, ok := indexKey(cursor.value(col)); ok {
+			index[key] = append(index[key], position{cursor.x, cursor.y})
+		}
+		if err := cursor.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if v.indexes == nil {
+		v.indexes = make(map[int]map[string][]position)
+	}
+	v.indexes[col] = index
+	return index, nil
+}
+
 type jsonCursor struct {
 	*jsonTable
-	current   *json.ASTNode
-	queryRoot *json.ASTNode
-	columns   []string
-	eof       bool
-	x         int
-	y         int
+	rows    []row // Rows of the current record.
+	loaded  int   // Index of the record rows belong to, or -1.
+	columns []string
+	eof     bool
+	x       int // Index of the current record.
+	y       int // Index of the current row within rows.
+
+	// Constraints rows must satisfy.
+	constraints []constraint
+
+	// Rows to visit when using an index, and the index of the current one.
+	indexed    bool
+	candidates []position
+	next       int
 }
 
-func (vc *jsonCursor) Column(c *sqlite3.SQLiteContext, col int) error {
-	// Retrieve the original column name.
-	columnName := vc.columns[col]
-	splitColumnName := strings.Split(columnName, ".")
-
-	// Try and find the AST node corresponding to the column.
-	rowNode := vc.current
-	var columnNode *json.ASTNode = nil
-	if len(splitColumnName) > 1 {
-		// Column could be object key or aliased table.
-		if splitColumnName[0] != vc.table {
-			columnName = splitColumnName[len(splitColumnName)-1]
-			if rowNode.Value == json.JSON_VALUE_OBJECT {
-				columnNode = json.FindNode(rowNode, columnName)
-			} else if rowNode.Value == json.JSON_VALUE_ARRAY {
-				columnNode = json.FindNode(rowNode.Values[vc.y], columnName)
-			}
-		} else {
-			if rowNode.Value == json.JSON_VALUE_OBJECT {
-				columnNode = json.FindNode(rowNode, splitColumnName[0])
-			} else if rowNode.Value == json.JSON_VALUE_ARRAY {
-				columnNode = json.FindNode(rowNode.Values[vc.y], splitColumnName[0])
-			}
+// row returns the AST node of the current row.
+func (vc *jsonCursor) row() *json.ASTNode {
+	return vc.rows[vc.y].node
+}
+
+// load finds the rows of the record at the current position, if not already
+// found.
+func (vc *jsonCursor) load(record *json.ASTNode) {
+	if vc.loaded != vc.x {
+		vc.rows = appendRows(vc.rows[:0], record, vc.path)
+		vc.loaded = vc.x
+	}
+
+	// Nested tables are found by records holding them, even if empty.
+	if vc.path != "" && !vc.clientData.found[vc.table] {
+		vc.clientData.found[vc.table] = len(json.FindAll(record, vc.path)) > 0
+	}
+}
+
+// appendRows appends the rows of the table at path within a record to rows.
+//
+// The top-level table has a single row per record. The table nodes of nested
+// tables are found by descending through the path of the table, expanding each
+// array met along the way, and skipping elements missing the rest of the path.
+// Each element of a table node that is an array is a row, otherwise the table
+// node itself is a row.
+func appendRows(rows []row, record *json.ASTNode, path string) []row {
+	if path == "" {
+		return append(rows, row{node: record, index: -1})
+	}
+
+	for _, match := range json.FindAll(record, path) {
+		if match.Node.Value != json.JSON_VALUE_ARRAY {
+			rows = append(rows, row{node: match.Node, index: -1, pointer: match.Pointer})
+			continue
 		}
-	} else {
-		if rowNode.Value == json.JSON_VALUE_OBJECT {
-			columnNode = json.FindNode(rowNode, columnName)
-		} else if rowNode.Value == json.JSON_VALUE_ARRAY {
-			columnNode = json.FindNode(rowNode.Values[vc.y], columnName)
+		for i, value := range match.Node.Values {
+			rows = append(rows, row{
+				node:    value,
+				index:   i,
+				pointer: match.Pointer + "/" + strconv.Itoa(i),
+			})
 		}
 	}
+	return rows
+}
 
-	if columnNode == nil {
-		c.ResultNull()
+// seek moves the cursor forward to the first row at or after the current
+// position, skipping records that do not contain the table.
+func (vc *jsonCursor) seek() error {
+	if vc.indexed {
+		if vc.next >= len(vc.candidates) {
+			vc.eof = true
+			return nil
+		}
+
+		vc.x, vc.y = vc.candidates[vc.next].x, vc.candidates[vc.next].y
+		record, err := vc.source.record(vc.x)
+		if err != nil {
+			return err
+		}
+		vc.load(record)
+		return nil
+	}
+
+	for {
+		record, err := vc.source.record(vc.x)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			vc.eof = true
+			return nil
+		}
+
+		vc.load(record)
+		if vc.y < len(vc.rows) {
+			return nil
+		}
+
+		vc.x++
+		vc.y = 0
+	}
+}
+
+// advance moves the cursor to the next row, regardless of the constraints.
+func (vc *jsonCursor) advance() error {
+	if vc.indexed {
+		vc.next++
+		return vc.seek()
+	}
+
+	vc.y++
+	if vc.y < len(vc.rows) {
+		return nil
+	}
+
+	vc.x++
+	vc.y = 0
+	return vc.seek()
+}
+
+// skip moves the cursor forward to the first row satisfying the constraints.
+func (vc *jsonCursor) skip() error {
+	for !vc.eof && !vc.matches() {
+		if err := vc.advance(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matches returns true if the current row satisfies the constraints.
+func (vc *jsonCursor) matches() bool {
+	for i := range vc.constraints {
+		if !vc.constraints[i].matches(vc.value(vc.constraints[i].column)) {
+			return false
+		}
+	}
+	return true
+}
+
+// columnNode returns the AST node of a column of the current row, or nil if
+// the row has no such column.
+func (vc *jsonCursor) columnNode(col int) *json.ASTNode {
+	if col >= len(vc.columns) {
 		return nil
 	}
 
-	switch columnNode.Value {
+	if path, ok := vc.clientData.paths[vc.columns[col]]; ok {
+		return findPath(vc.row(), path)
+	}
+
+	// Rows that are not objects are the value of the value column.
+	if row := vc.row(); row.Value != json.JSON_VALUE_OBJECT && strings.EqualFold(vc.columns[col], valueColumn) {
+		return row
+	}
+	return json.FindMember(vc.row(), vc.columns[col])
+}
+
+// findPath returns the AST node at a path within a node, or nil if there is no
+// such node.
+func findPath(node *json.ASTNode, path sqlj.Path) *json.ASTNode {
+	for _, element := range path {
+		if node == nil {
+			return nil
+		}
+		if !element.IsIndex {
+			node = json.FindMember(node, element.Name)
+			continue
+		}
+
+		if node.Value != json.JSON_VALUE_ARRAY {
+			return nil
+		}
+		i := element.Index
+		if i < 0 {
+			i += len(node.Values)
+		}
+		if i < 0 || i >= len(node.Values) {
+			return nil
+		}
+		node = node.Values[i]
+	}
+	return node
+}
+
+// sqlValue returns the value passed to SQLite for a node. Booleans are passed
+// as integers and objects and arrays as JSON text.
+func sqlValue(node *json.ASTNode) interface{} {
+	if node == nil {
+		return nil
+	}
+
+	switch node.Value {
 	case json.JSON_VALUE_OBJECT, json.JSON_VALUE_ARRAY:
 		buf := bytes.NewBuffer(nil)
-		json.PrettyPrint(buf, columnNode, true)
-		c.ResultText(string(buf.Bytes()))
+		json.PrettyPrint(buf, node, true)
+		return buf.String()
 	case json.JSON_VALUE_NUMBER:
-		c.ResultDouble(columnNode.Number)
+		// Integers are passed to SQLite exactly where they fit in an int64.
+		if value, err := strconv.ParseInt(node.Literal, 10, 64); err == nil {
+			return value
+		}
+		return node.Number
 	case json.JSON_VALUE_STRING:
-		c.ResultText(columnNode.String)
-	case json.JSON_VALUE_NULL:
-		c.ResultNull()
+		return node.String
 	case json.JSON_VALUE_TRUE:
-		c.ResultBool(true)
+		return int64(1)
 	case json.JSON_VALUE_FALSE:
-		c.ResultBool(false)
+		return int64(0)
+	default:
+		return nil
 	}
-	return nil
 }
 
-func (vc *jsonCursor) Filter(idxNum int, idxStr string, vals []interface{}) error {
-	// Reset our cursor.
-	if vc.eof {
-		vc.x = 0
-		vc.y = 0
-		vc.current = vc.queryRoot
-		vc.eof = false
+// value returns the value of a column of the current row, as passed to SQLite.
+func (vc *jsonCursor) value(col int) interface{} {
+	if col < len(vc.columns) {
+		return sqlValue(vc.columnNode(col))
 	}
-
-	return nil
+	return vc.hiddenValue(col - len(vc.columns))
 }
 
-func (vc *jsonCursor) Next() error {
-	// Object queries only execute a single row.
-	if vc.queryRoot.Value == json.JSON_VALUE_OBJECT {
-		vc.eof = true
+// hiddenValue returns the value of one of the hidden columns of the current
+// row.
+func (vc *jsonCursor) hiddenValue(hidden int) interface{} {
+	switch hidden {
+	case hiddenIndex:
+		if vc.path == "" {
+			return int64(vc.source.offset + vc.x)
+		}
+		if vc.rows[vc.y].index >= 0 {
+			return int64(vc.rows[vc.y].index)
+		}
+		return nil
+	case hiddenPath:
+		return vc.rows[vc.y].pointer
+	case hiddenParentIndex:
+		if vc.path == "" {
+			return nil
+		}
+		return int64(vc.source.offset + vc.x)
+	case hiddenRaw:
+		buf := bytes.NewBuffer(nil)
+		json.PrettyPrint(buf, vc.row(), true)
+		return buf.String()
+	case hiddenFile:
+		return vc.source.file(vc.x)
+	default:
 		return nil
 	}
+}
 
-	// Array queries might be on nested arrays.
-	vc.y++
-	if vc.y >= len(vc.current.Values) {
-		vc.y = 0
-		vc.x++
-		if vc.x >= len(vc.queryRoot.Values) || vc.queryRoot == vc.current {
-			vc.eof = true
-			return nil
+func (vc *jsonCursor) Column(c *sqlite3.SQLiteContext, col int) error {
+	if col >= len(vc.columns) {
+		value := vc.hiddenValue(col - len(vc.columns))
+		switch {
+		case col-len(vc.columns) == hiddenRaw:
+			vc.types[col] |= containerValues
+		case value != nil:
+			vc.types[col] |= typesOf(value)
 		}
-		vc.current = json.FindNode(vc.queryRoot.Values[vc.x], vc.table)
+		result(c, value)
+		return nil
 	}
+
+	columnNode := vc.columnNode(col)
+	vc.types[col] |= nodeTypes(columnNode)
+	result(c, vc.last.set(col, columnNode))
 	return nil
 }
 
+// nodeTypes returns the type of the value of a node, or no types for NULL.
+func nodeTypes(node *json.ASTNode) valueTypes {
+	if node == nil {
+		return 0
+	}
+
+	switch node.Value {
+	case json.JSON_VALUE_OBJECT, json.JSON_VALUE_ARRAY:
+		return containerValues
+	case json.JSON_VALUE_NUMBER:
+		return numberValues
+	case json.JSON_VALUE_STRING:
+		return stringValues
+	case json.JSON_VALUE_TRUE, json.JSON_VALUE_FALSE:
+		return booleanValues
+	default:
+		return 0
+	}
+}
+
+// typesOf returns the types of a value returned by sqlValue.
+func typesOf(value interface{}) valueTypes {
+	switch value.(type) {
+	case int64, float64:
+		return numberValues
+	default:
+		return stringValues
+	}
+}
+
+// nonEmpty backs the empty strings passed to SQLite. go-sqlite3 passes the
+// data pointer of a string to SQLite as is, and SQLite treats a nil pointer as
+// NULL rather than an empty string.
+const nonEmpty = "\x00"
+
+// result sets the result of a call to Column to a value returned by sqlValue.
+func result(c *sqlite3.SQLiteContext, value interface{}) {
+	switch value := value.(type) {
+	case int64:
+		c.ResultInt64(value)
+	case float64:
+		c.ResultDouble(value)
+	case string:
+		if value == "" {
+			value = nonEmpty[:0]
+		}
+		c.ResultText(value)
+	default:
+		c.ResultNull()
+	}
+}
+
+// reset moves the cursor back to the start of the table, with a new set of
+// constraints.
+func (vc *jsonCursor) reset(constraints []constraint) {
+	vc.constraints = constraints
+	vc.loaded = -1
+	vc.x = 0
+	vc.y = 0
+	vc.eof = false
+	vc.indexed = false
+}
+
+func (vc *jsonCursor) Filter(idxNum int, idxStr string, vals []interface{}) error {
+	if idxNum < 0 || idxNum >= len(vc.plans) {
+		return fmt.Errorf("unknown index %d", idxNum)
+	}
+	constraints, err := vc.plans[idxNum].bind(vals)
+	if err != nil {
+		return err
+	}
+	vc.reset(constraints)
+
+	// Where the input is held in memory, equality constraints are looked up
+	// in an index rather than scanning every row.
+	if vc.source.retain {
+		for i := range vc.constraints {
+			if vc.constraints[i].op != sqlite3.OpEQ {
+				continue
+			}
+
+			index, err := vc.index(vc.constraints[i].column)
+			if err != nil {
+				return err
+			}
+			key, _ := indexKey(vc.constraints[i].value)
+			vc.indexed = true
+			vc.candidates = index[key]
+			vc.next = 0
+			break
+		}
+	}
+
+	if err := vc.seek(); err != nil {
+		return err
+	}
+	return vc.skip()
+}
+
+func (vc *jsonCursor) Next() error {
+	if err := vc.advance(); err != nil {
+		return err
+	}
+	return vc.skip()
+}
+
 func (vc *jsonCursor) EOF() bool {
+	if vc.eof {
+		vc.last.clear()
+	}
 	return vc.eof
 }
 
@@ -226,18 +675,113 @@ func (vc *jsonCursor) Close() error {
 	return nil
 }
 
-func Exec(clientData *ClientData) []*json.ASTNode {
-	// Extract 'CREATE TABLE ...' statements from SQL AST required to declare
-	// the virtual tables for the query.
-	createTableStmts := sqlj.SchemasFromStmt(clientData.SqlAst)
+// Exec runs the query described by clientData, calling emit with each result
+// row as it is produced. Rows are objects with a member per result column.
+func Exec(clientData *ClientData, emit func(row *json.ASTNode)) error {
+	// Extract the columns referenced for each table in the query.
+	schema := sqlj.SchemasFromStmt(clientData.SqlAst)
+
+	// Records are streamed from the inputs unless the query reads more than
+	// one source, such as a table joined with another table, a table-valued
+	// function or a sub-query, or common tables are referenced, in which case
+	// SQLite may need to scan an input repeatedly, or the columns of a table
+	// must first be discovered from the data. Recursive common tables in
+	// particular scan their tables once for each step of the recursion.
+	if len(clientData.Inputs) == 0 {
+		return errors.New("no inputs to query")
+	}
+	retain := sqlj.CountIdentifiers(clientData.SqlAst, sqlj.Source) > 1 ||
+		sqlj.CountIdentifiers(clientData.SqlAst, sqlj.CommonTable) > 0
+	clientData.sources = make([]*source, len(clientData.Inputs))
+	for i, input := range clientData.Inputs {
+		clientData.sources[i] = &source{
+			reader: input.Reader,
+			retain: retain,
+		}
+	}
+	clientData.sources[0].nth = clientData.Nth
+
+	// Column references with paths are rewritten to refer to columns named
+	// after their paths, and arrows to query the extract table.
+	clientData.paths = schema.Paths
+	query := sqlj.Rewrite(clientData.Query, clientData.SqlAst)
+
+	clientData.pushdown = canPushdown(query)
+	clientData.multiScan = isMultiScan(query)
+	clientData.found = make(map[string]bool)
+	tables := sqlj.ExtractIdentifiers(clientData.SqlAst, sqlj.Table)
+	for i := 0; i < len(tables); i++ {
+		if !schema.Star[i] {
+			continue
+		}
+
+		source, path := clientData.resolveTable(tables[i])
+		source.retain = true
+		columns, err := discoverColumns(source, path, schema.Columns[i])
+		if err != nil {
+			return sourceError(clientData, err)
+		}
+		schema.Columns[i] = columns
+	}
 
-	// Register our module and the hook to be invoked on each
+	// Hidden columns are declared separately, so are not columns of the data.
+	for i := 0; i < len(tables); i++ {
+		columns := make([]string, 0, len(schema.Columns[i]))
+		for _, column := range schema.Columns[i] {
+			if !isHidden(column) {
+				columns = append(columns, column)
+			}
+		}
+		schema.Columns[i] = columns
+	}
+
+	// Allocate the types produced by each column of each table up front, as
+	// the tables retain slices of them. The columns of the each and extract
+	// tables follow those of the JSON tables.
+	firsts := make([]int, len(tables))
+	for i := 0; i < len(tables); i++ {
+		firsts[i] = len(clientData.types)
+		clientData.types = append(clientData.types, make([]valueTypes, len(schema.Columns[i])+len(hiddenColumns))...)
+	}
+	var members []string
+	for _, column := range schema.FunctionColumns {
+		if !isEachColumn(column) {
+			members = append(members, column)
+		}
+	}
+	eachFirst := len(clientData.types)
+	clientData.types = append(clientData.types, make([]valueTypes, 2+len(members))...)
+	extractFirst := len(clientData.types)
+	clientData.types = append(clientData.types, 0, 0)
+	clientData.last = make(lastValues, len(clientData.types))
+
+	// Register our modules and the hook to be invoked on each
 	// 'CREATE VIRTUAL TABLE ...' statement.
 	jsonModule := jsonModule{
 		clientData: clientData,
 	}
+	eachModule := eachModule{
+		types:   clientData.types[eachFirst:],
+		last:    clientData.last[eachFirst:],
+		first:   eachFirst,
+		members: members,
+		paths:   clientData.paths,
+	}
+	extractModule := extractModule{
+		types: clientData.types[extractFirst:],
+		last:  clientData.last[extractFirst:],
+		first: extractFirst,
+	}
 	sql.Register(Driver, &sqlite3.SQLiteDriver{
 		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			for _, name := range eachFunctions {
+				if err := conn.CreateModule(name, &eachModule); err != nil {
+					return err
+				}
+			}
+			if err := conn.CreateModule(sqlj.ExtractFunction, &extractModule); err != nil {
+				return err
+			}
 			return conn.CreateModule("sqjson", &jsonModule)
 		},
 	})
@@ -245,67 +789,324 @@ func Exec(clientData *ClientData) []*json.ASTNode {
 	// Open our database connection.
 	db, err := sql.Open(Driver, ":memory:")
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	defer db.Close()
 
 	// For each table in our query, create the corresponding virtual table.
 	// This will call the CreateModule hook to declare the virtual table and
 	// initialize the associate jsonTable instance.
-	tables := sqlj.ExtractIdentifiers(clientData.SqlAst, sqlj.Table)
 	for i := 0; i < len(tables); i++ {
-		jsonModule.createTableStmt = &(createTableStmts.CreateTableStmts[i])
+		first, last := firsts[i], firsts[i]+len(schema.Columns[i])+len(hiddenColumns)
+
+		createTableStmt := createTableStmt(tables[i], schema.Columns[i], first, clientData.paths)
+		jsonModule.createTableStmt = &createTableStmt
 		jsonModule.table = &tables[i]
-		jsonModule.columns = &(createTableStmts.Columns[i])
-		_, err = db.Exec(fmt.Sprintf("CREATE VIRTUAL TABLE %s USING sqjson", tables[i]))
+		jsonModule.columns = &(schema.Columns[i])
+		jsonModule.types = clientData.types[first:last:last]
+		jsonModule.last = clientData.last[first:last:last]
+		jsonModule.source, jsonModule.path = clientData.resolveTable(tables[i])
+		_, err = db.Exec(fmt.Sprintf("CREATE VIRTUAL TABLE %s USING sqjson", quoteTable(tables[i])))
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 	}
 
-	stmt, err := db.Prepare(clientData.Query)
+	stmt, err := db.Prepare(query)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	defer stmt.Close()
 
-	nodes := make([]*json.ASTNode, 0)
 	rows, err := stmt.Query()
+	if err != nil {
+		return sourceError(clientData, err)
+	}
 	defer rows.Close()
 
+	// Result columns that refer directly to a table column report the
+	// column's declared type, which identifies the column.
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+	origins := make([]int, len(columnTypes))
+	for i := range columnTypes {
+		origins[i] = -1
+		_, _ = fmt.Sscanf(columnTypes[i].DatabaseTypeName(), columnType, &origins[i])
+	}
+
+	names, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	names = uniqueNames(names)
+
+	// Rows are held back while a nested table has not been found in any
+	// record read, so that a misspelled table fails without partial output.
+	var pending []*json.ASTNode
 	for rows.Next() {
-		columns, _ := rows.Columns()
-		results := make([]interface{}, len(columns))
-		for i, _ := range results {
-			var value string
-			results[i] = &value
-		}
-
-		rows.Scan(results...)
-		for _, result := range results {
-			resultString := *result.(*string)
-			parsedNumber, err := strconv.ParseFloat(resultString, 64)
-			if err == nil {
-				nodes = append(nodes, &json.ASTNode{
-					Value:   json.JSON_VALUE_NUMBER,
-					Name:    "",
-					Members: nil,
-					Values:  nil,
-					Number:  parsedNumber,
-					String:  "",
-				})
-			} else {
-				nodes = append(nodes, &json.ASTNode{
-					Value:   json.JSON_VALUE_STRING,
-					Name:    "",
-					Members: nil,
-					Values:  nil,
-					Number:  0,
-					String:  resultString,
-				})
+		results := make([]interface{}, len(columnTypes))
+		for i := range results {
+			results[i] = new(interface{})
+		}
+
+		if err := rows.Scan(results...); err != nil {
+			return sourceError(clientData, err)
+		}
+
+		row := &json.ASTNode{Value: json.JSON_VALUE_OBJECT}
+		for i, result := range results {
+			var types valueTypes
+			var last lastValue
+			if origins[i] >= 0 {
+				types, last = clientData.types[origins[i]], clientData.last[origins[i]]
+			}
+			member := resultNode(*result.(*interface{}), types, last)
+			member.Name = names[i]
+			row.Members = append(row.Members, member)
+		}
+		if clientData.missingTable(tables) != "" {
+			pending = append(pending, row)
+			continue
+		}
+		for _, row := range pending {
+			emit(row)
+		}
+		pending = nil
+		emit(row)
+	}
+	if err := sourceError(clientData, rows.Err()); err != nil {
+		return err
+	}
+
+	// Names matching neither an input nor a nested table of any record read
+	// are most likely mistakes, so are reported rather than queried as empty.
+	if table := clientData.missingTable(tables); table != "" {
+		return fmt.Errorf("no such table: %s", table)
+	}
+	for _, row := range pending {
+		emit(row)
+	}
+	return nil
+}
+
+// missingTable returns the first of tables that is a nested table not found
+// in any record read so far, or an empty string if there is none. When a
+// single record is selected, tables missing from it are not reported, since
+// other records may hold them.
+func (clientData *ClientData) missingTable(tables []string) string {
+	if clientData.Nth != nil {
+		return ""
+	}
+
+	for _, table := range tables {
+		if found, ok := clientData.found[table]; ok && !found {
+			return table
+		}
+	}
+	return ""
+}
+
+// resolveTable returns the source of the records of a table, and the path of
+// the table node within each record. Tables named after an input hold its
+// top-level values, as does '[]' for the first input. As in SQLite, table
+// names are matched regardless of case. Other tables are nested tables of the
+// first input.
+func (clientData *ClientData) resolveTable(table string) (*source, string) {
+	for i, input := range clientData.Inputs {
+		if input.Name != "" && strings.EqualFold(input.Name, table) {
+			return clientData.sources[i], ""
+		}
+	}
+
+	if table == "[]" {
+		return clientData.sources[0], ""
+	}
+	return clientData.sources[0], table
+}
+
+// canPushdown returns true if constraints in a query can be evaluated by the
+// virtual tables. Our columns have no affinity and use the BINARY collation,
+// but a CAST or COLLATE can change how SQLite compares values, in which case
+// constraints are left to SQLite.
+func canPushdown(query string) bool {
+	scanner := sqlj.NewScanner([]byte(query))
+	for {
+		switch token, _ := scanner.ScanToken(); token {
+		case sqlj.EOF:
+			return true
+		case sqlj.CAST, sqlj.COLLATE:
+			return false
+		}
+	}
+}
+
+// isMultiScan returns true if a plan using constraints may scan a table more
+// than once. SQLite may evaluate the terms of an OR, or the values of an IN,
+// with a scan of the table each. Queries reading several sources, where a
+// table may be scanned for each row of another, hold their inputs in memory.
+func isMultiScan(query string) bool {
+	scanner := sqlj.NewScanner([]byte(query))
+	for {
+		switch token, _ := scanner.ScanToken(); token {
+		case sqlj.EOF:
+			return false
+		case sqlj.OR, sqlj.IN:
+			return true
+		}
+	}
+}
+
+// isHidden returns true if a column name refers to one of the hidden columns.
+func isHidden(column string) bool {
+	for _, hidden := range hiddenColumns {
+		if strings.EqualFold(column, hidden) {
+			return true
+		}
+	}
+	return false
+}
+
+// createTableStmt builds the statement declaring the schema of a virtual
+// table. Columns are numbered from first in their declared types, and are
+// followed by the hidden columns. Columns referenced with a path are also
+// hidden, so are not selected by 'SELECT *'.
+func createTableStmt(table string, columns []string, first int, paths map[string]sqlj.Path) string {
+	stmt := "CREATE TABLE IF NOT EXISTS " + quoteTable(table) + "("
+	for i, column := range columns {
+		stmt += util.EscapeString(column)
+		if _, ok := paths[column]; ok {
+			stmt += " HIDDEN"
+		}
+		stmt += " " + fmt.Sprintf(columnType, first+i) + ","
+	}
+	for i, column := range hiddenColumns {
+		if i > 0 {
+			stmt += ","
+		}
+		stmt += column + " HIDDEN " + fmt.Sprintf(columnType, first+len(columns)+i)
+	}
+	return stmt + ");"
+}
+
+// quoteTable quotes a table name for use in a statement. The '[]' table is
+// left as is, being the name SQLite reads as written.
+func quoteTable(table string) string {
+	if table == "[]" {
+		return table
+	}
+	return `"` + strings.ReplaceAll(table, `"`, `""`) + `"`
+}
+
+// discoverColumns reads the whole input to find the columns of the table at
+// path, being the union of the members of each of its rows, and the value
+// column where rows are not objects. Columns referenced by the query but not
+// present in the data are kept, following those discovered.
+func discoverColumns(source *source, path string, referenced []string) ([]string, error) {
+	var schema json.Schema
+	var rows []row
+	values := false
+	for i := 0; ; i++ {
+		record, err := source.record(i)
+		if err != nil {
+			return nil, err
+		}
+		if record == nil {
+			break
+		}
+
+		rows = appendRows(rows[:0], record, path)
+		for _, row := range rows {
+			schema.Add(row.node)
+			values = values || row.node.Value != json.JSON_VALUE_OBJECT
+		}
+	}
+
+	columns := schema.Columns
+	seen := make(map[string]bool)
+	for _, column := range columns {
+		seen[strings.ToLower(column)] = true
+	}
+	if values && !seen[valueColumn] {
+		columns = append(columns, valueColumn)
+		seen[valueColumn] = true
+	}
+	for _, column := range referenced {
+		if !seen[strings.ToLower(column)] {
+			columns = append(columns, column)
+		}
+	}
+	return columns, nil
+}
+
+// uniqueNames makes result column names unique, so they can be used as the
+// member names of a row. Repeated names have ":N" appended, as SQLite does when
+// naming the columns of a sub-query.
+func uniqueNames(names []string) []string {
+	seen := make(map[string]bool)
+	unique := make([]string, len(names))
+	for i, name := range names {
+		unique[i] = name
+		for n := 1; seen[unique[i]]; n++ {
+			unique[i] = fmt.Sprintf("%s:%d", name, n)
+		}
+		seen[unique[i]] = true
+	}
+	return unique
+}
+
+// resultNode converts a result value into an AST node. Values equal to the last
+// value of their column are converted back to its node. Otherwise, values from
+// a column that has only produced booleans, or only objects and arrays, are
+// converted back to their original types.
+func resultNode(value interface{}, types valueTypes, last lastValue) *json.ASTNode {
+	if last.node != nil && last.value == value {
+		node := *last.node
+		node.Name = ""
+		return &node
+	}
+
+	switch value := value.(type) {
+	case nil:
+		return &json.ASTNode{Value: json.JSON_VALUE_NULL}
+	case int64:
+		if types&(booleanValues|numberValues) == booleanValues && (value == 0 || value == 1) {
+			if value == 1 {
+				return &json.ASTNode{Value: json.JSON_VALUE_TRUE}
 			}
+			return &json.ASTNode{Value: json.JSON_VALUE_FALSE}
 		}
+		return &json.ASTNode{
+			Value:   json.JSON_VALUE_NUMBER,
+			Number:  float64(value),
+			Literal: strconv.FormatInt(value, 10),
+		}
+	case float64:
+		return &json.ASTNode{Value: json.JSON_VALUE_NUMBER, Number: value}
+	case string:
+		if types&(containerValues|stringValues) == containerValues {
+			parser := json.NewParser(json.NewTokenizer(strings.NewReader(value)))
+			if err := parser.Parse(); err == nil {
+				return &parser.Ast
+			}
+		}
+		return &json.ASTNode{Value: json.JSON_VALUE_STRING, String: value}
+	case []byte:
+		return &json.ASTNode{Value: json.JSON_VALUE_STRING, String: string(value)}
+	default:
+		return &json.ASTNode{Value: json.JSON_VALUE_STRING, String: fmt.Sprint(value)}
 	}
+}
 
-	return nodes
+// sourceError returns the error encountered reading a JSON input, if any, in
+// preference to err. Errors from the virtual table only reach us via SQLite as
+// text, so this recovers the original error value.
+func sourceError(clientData *ClientData, err error) error {
+	for _, source := range clientData.sources {
+		if source.err != nil {
+			return source.err
+		}
+	}
+	return err
 }
@@ -0,0 +1,46 @@
+package vtable
+
+import (
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestBestIndex(t *testing.T) {
+	type TestCase struct {
+		query    string
+		retain   bool
+		expected bool
+	}
+	cases := []TestCase{
+		{"SELECT id FROM [] WHERE id = 1", false, true},
+		{"SELECT id FROM [] WHERE id = 1 OR id = 2", false, false},
+		{"SELECT id FROM [] WHERE id IN (1, 2)", false, false},
+		{"SELECT id FROM [] WHERE id IN (1, 2)", true, true},
+		{"SELECT id FROM [] WHERE CAST(id AS TEXT) = '1'", true, false},
+	}
+
+	for _, test := range cases {
+		// Arrange.
+		table := &jsonTable{
+			clientData: &ClientData{
+				pushdown:  canPushdown(test.query),
+				multiScan: isMultiScan(test.query),
+			},
+			columns: []string{"id"},
+			source:  &source{retain: test.retain},
+		}
+		csts := []sqlite3.InfoConstraint{{Column: 0, Op: sqlite3.OpEQ, Usable: true}}
+
+		// Act.
+		result, err := table.BestIndex(csts, nil)
+
+		// Assert.
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %v", test.query, err)
+		}
+		if result.Used[0] != test.expected {
+			t.Errorf("expected constraint claimed %t for %q, got %t", test.expected, test.query, result.Used[0])
+		}
+	}
+}